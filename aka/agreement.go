@@ -0,0 +1,48 @@
+package aka
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"strings"
+)
+
+// AssertKAUSFAgreement computes KAUSF on both the UDM and the UE side, and returns
+// an error describing which of the inputs diverged if they don't match.
+//
+// The error contains the input strings S of both sides and tells whether SNN,
+// SQN xor AK or the key CK||IK differs, which are the usual causes of the mismatch.
+func AssertKAUSFAgreement(udm, ue *Aka) error {
+	udmKAUSF, err := udm.ComputeKAUSF()
+	if err != nil {
+		return fmt.Errorf("ComputeKAUSF() failed on UDM: %w", err)
+	}
+	ueKAUSF, err := ue.ComputeKAUSF()
+	if err != nil {
+		return fmt.Errorf("ComputeKAUSF() failed on UE: %w", err)
+	}
+
+	if hmac.Equal(udmKAUSF, ueKAUSF) {
+		return nil
+	}
+
+	var diffs []string
+	if udmSNN, ueSNN := string(udm.SNN), string(ue.SNN); udmSNN != ueSNN {
+		diffs = append(diffs, fmt.Sprintf("SNN: %q != %q", udmSNN, ueSNN))
+	}
+	udmConcealed := udm.sqnXorAK()
+	ueConcealed := ue.sqnXorAK()
+	if !hmac.Equal(udmConcealed, ueConcealed) {
+		diffs = append(diffs, fmt.Sprintf("SQN xor AK: %x != %x", udmConcealed, ueConcealed))
+	}
+	if !hmac.Equal(udm.derivationKey(), ue.derivationKey()) {
+		diffs = append(diffs, "CK||IK differs")
+	}
+	if udm.TruncateKeysTo != ue.TruncateKeysTo {
+		diffs = append(diffs, fmt.Sprintf("TruncateKeysTo: %d != %d", udm.TruncateKeysTo, ue.TruncateKeysTo))
+	}
+
+	return fmt.Errorf(
+		"KAUSF mismatch (%s): S on UDM = %x, S on UE = %x",
+		strings.Join(diffs, ", "), udm.kausfInput(udmConcealed), ue.kausfInput(ueConcealed),
+	)
+}
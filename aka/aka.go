@@ -2,14 +2,30 @@ package aka
 
 import (
 	"5G_AKA/milenage"
-	"crypto/hmac"
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
 )
 
+// ErrNot5GChallenge is returned by UEComputeFromAUTN if the "separation bit"
+// in AMF of AUTN is 0, i.e. the challenge is not for 5G (Annex H, TS 33.102).
+var ErrNot5GChallenge = errors.New("AMF separation bit is 0: not a 5G challenge")
+
+// ErrNoAuthVectors is returned by the methods that need the values computed by
+// f1-f5, e.g. RAND or XRES*, if Aka is created without them by NewFromKAUSF.
+var ErrNoAuthVectors = errors.New("no AuthVectors: Aka is created from KAUSF only")
+
+// ABBAInitialReg is the ABBA parameter (A.7.1, TS 33.501) used when no
+// security feature needs to be indicated, e.g. on the initial registration.
+const ABBAInitialReg uint16 = 0x0000
+
 type Aka struct {
-	// Milenage object
-	mil milenage.Milenage
+	// av is the output of f1-f5 that the keys are derived from, usually *milenage.Milenage
+	av AuthVectors
 
 	// SNN
 	SNN []byte
@@ -22,11 +38,52 @@ type Aka struct {
 	KAMF  []byte
 
 	HXRESStar []byte
+
+	// KAKMA and A-TID are derived from KAUSF for AKMA
+	KAKMA []byte
+	ATID  []byte
+
+	// RID is the routing indicator used in A-KID
+	RID string
+
+	// Store persists KAUSF by SUPI in ComputeKAUSF if set
+	Store KAUSFStore
+
+	// TruncateKeysTo truncates KAUSF, KSEAF and KAMF to the first TruncateKeysTo
+	// bytes if it's between 1 and 31. The keys derived from them, e.g. KAMF' and
+	// KAKMA, are not truncated.
+	//
+	// NOT COMPLIANT WITH TS 33.501: the keys are the full 256-bit output of the KDF.
+	// This is only to reproduce a peer that wrongly truncates them while debugging.
+	TruncateKeysTo int
+
+	// NgKSI is the ngKSI associated with KAMF, packed as by SetNgKSI
+	NgKSI uint8
+
+	// ABBA is the ABBA parameter used in ComputeKAMF, ABBAInitialReg by default
+	ABBA uint16
+
+	// Hash is the hash function used in HMAC in the derivations of KAUSF, KSEAF and KAMF.
+	// It defaults to SHA-256 if nil. The other derivations, e.g. of KAMF' and KAKMA,
+	// always use SHA-256.
+	//
+	// NOT COMPLIANT WITH TS 33.501 unless SHA-256: the KDF is HMAC-SHA-256 (Annex B.2.0,
+	// TS 33.220). This is only to experiment with other hash functions.
+	Hash func() hash.Hash
+
+	// kseafSNN is the SNN that KSEAF was last derived under
+	kseafSNN string
+
+	// trace is called with the KDF input strings if set
+	trace func(name string, value []byte)
 }
 
-func New(mil milenage.Milenage, SNN string, SUPI string) *Aka {
+// New creates Aka that derives the keys from av, e.g. *milenage.Milenage.
+//
+// av is not copied, so the values computed on it later are reflected.
+func New(av AuthVectors, SNN string, SUPI string) *Aka {
 	a := &Aka{
-		mil:   mil,
+		av:    av,
 		SNN:   []byte(SNN),
 		SUPI:  []byte(SUPI),
 		KAUSF: make([]byte, 32),
@@ -37,89 +94,297 @@ func New(mil milenage.Milenage, SNN string, SUPI string) *Aka {
 	return a
 }
 
-func (a *Aka) ComputeKAUSF() ([]byte, error) {
-	sqnXorAk := milenage.Xor(a.mil.SQN, a.mil.AK)
-	sqnXorAkLen := byteArrayLen2B(sqnXorAk)
-	sNNLen := byteArrayLen2B(a.SNN)
+// NewFromKAUSF creates Aka that resumes from a KAUSF obtained earlier (see SetKAUSF)
+// instead of the output of f1-f5, so only ComputeKSEAF, ComputeKAMF and the derivations
+// from KAUSF can be used on it. The others return ErrNoAuthVectors.
+func NewFromKAUSF(kausf []byte, SNN string, SUPI string) (*Aka, error) {
+	a := New(nil, SNN, SUPI)
+	if err := a.SetKAUSF(kausf); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
 
-	// Construct the input string
-	inputString := []byte{0x6a}
-	inputString = append(inputString, a.SNN...)
-	inputString = append(inputString, sNNLen...)
-	inputString = append(inputString, sqnXorAk...)
-	inputString = append(inputString, sqnXorAkLen...)
+// NewWithValidation is New, but returns an error if av is nil or any of the values
+// in av needed to derive the keys is missing or has a wrong length, i.e. it's expected
+// that F1, F2345 and ComputeRESStar have already been called for a *milenage.Milenage.
+func NewWithValidation(av AuthVectors, SNN string, SUPI string) (*Aka, error) {
+	if m, ok := av.(*milenage.Milenage); av == nil || ok && m == nil {
+		return nil, fmt.Errorf("AuthVectors should not be nil")
+	}
+	for _, f := range []struct {
+		name  string
+		value []byte
+		size  int
+	}{
+		{"SQN", av.GetSQN(), 6},
+		{"AK", av.GetAK(), 6},
+		{"CK", av.GetCK(), 16},
+		{"IK", av.GetIK(), 16},
+		{"RAND", av.GetRAND(), 16},
+		{"RESStar", av.GetRESStar(), 16},
+	} {
+		if len(f.value) != f.size {
+			return nil, fmt.Errorf("length of %s should be %d, got: %d", f.name, f.size, len(f.value))
+		}
+	}
 
-	// Construct the input key
-	inputKey := append(a.mil.CK, a.mil.IK...)
+	return New(av, SNN, SUPI), nil
+}
+
+// ComputeKAUSF computes KAUSF (A.2, TS 33.501) with SQN xor AK, where AK should have
+// been computed by F2345 for the current RAND as for AUTN; an error is returned otherwise
+// if the AuthVectors can tell it (see milenage.Milenage.CheckAK).
+func (a *Aka) ComputeKAUSF() ([]byte, error) {
+	if a.av == nil {
+		return nil, ErrNoAuthVectors
+	}
+	if c, ok := a.av.(interface{ CheckAK() error }); ok {
+		if err := c.CheckAK(); err != nil {
+			return nil, err
+		}
+	}
 
-	// Compute HMAC-SHA256
-	h := hmac.New(sha256.New, inputKey)
-	h.Write(inputString)
-	kausf := h.Sum(nil) // Get the hash result
+	kausf := a.deriveKAUSF(a.sqnXorAK())
+
+	if a.Store != nil {
+		if err := a.Store.Set(string(a.SUPI), kausf); err != nil {
+			return nil, fmt.Errorf("failed to store KAUSF: %w", err)
+		}
+	}
 
 	a.KAUSF = kausf
 	return kausf, nil
 }
 
-func (a *Aka) ComputeKSEAF() ([]byte, error) {
-	sNNLen := byteArrayLen2B(a.SNN)
-
-	// Construct the input string
-	inputString := []byte{0x6c}
-	inputString = append(inputString, a.SNN...)
-	inputString = append(inputString, sNNLen...)
-
-	// Construct the input key
-	inputKey := a.KAUSF
+// SetKAUSF sets a KAUSF obtained earlier, e.g. the one cached by the AUSF, so that
+// ComputeKSEAF and ComputeKAMF can derive the downstream keys without f1-f5.
+// kausf is copied.
+func (a *Aka) SetKAUSF(kausf []byte) error {
+	if len(kausf) != 32 {
+		return fmt.Errorf("%w: length of KAUSF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kausf))
+	}
+	a.KAUSF = bytes.Clone(kausf)
+	return nil
+}
 
-	// Compute HMAC-SHA256
-	h := hmac.New(sha256.New, inputKey)
-	h.Write(inputString)
-	kseaf := h.Sum(nil) // Get the hash result
+func (a *Aka) ComputeKSEAF() ([]byte, error) {
+	kseaf := a.kdf("S(KSEAF)", a.KAUSF, 0x6c, a.SNN)
 
 	a.KSEAF = kseaf
+	a.kseafSNN = string(a.SNN)
 	return kseaf, nil
 }
 
-func (a *Aka) ComputeKAMF() ([]byte, error) {
-	sUPILen := byteArrayLen2B(a.SUPI)
+// KSEAFBinding returns the SNN that the current KSEAF was derived under by ComputeKSEAF,
+// so that the SEAF can reject a KSEAF bound to a serving network other than its own.
+// ok is false if KSEAF has not been computed yet.
+func (a *Aka) KSEAFBinding() (snn string, ok bool) {
+	if a.kseafSNN == "" {
+		return "", false
+	}
+	return a.kseafSNN, true
+}
 
-	// Construct the input string
-	abba := []byte{0x00, 0x00}
-	inputString := append([]byte{0x6d}, a.SUPI...)
-	inputString = append(inputString, sUPILen...)
-	inputString = append(inputString, abba...)
-	inputString = append(inputString, []byte{0x00, 0x02}...)
+// ComputeKAMF computes KAMF (A.7, TS 33.501) with ABBA. The SUPI goes into P0 as
+// returned by canonicalSUPI, so KAMF is the same whether SUPI is given as
+// "imsi-001010123456789" or "001010123456789".
+//
+// To derive KAMF on re-authentication with the ABBA reflecting the negotiated
+// features, set ABBA to the value sent to the UE before calling this.
+func (a *Aka) ComputeKAMF() ([]byte, error) {
+	return a.computeKAMF(a.ABBA)
+}
 
-	// Construct the input key
-	inputKey := a.KAUSF
+// ComputeKAMFInitial computes KAMF with ABBAInitialReg regardless of ABBA.
+func (a *Aka) ComputeKAMFInitial() ([]byte, error) {
+	return a.computeKAMF(ABBAInitialReg)
+}
 
-	// Compute HMAC-SHA256
-	h := hmac.New(sha256.New, inputKey)
-	h.Write(inputString)
-	kamf := h.Sum(nil)
+func (a *Aka) computeKAMF(abbaValue uint16) ([]byte, error) {
+	abba := make([]byte, 2)
+	binary.BigEndian.PutUint16(abba, abbaValue)
+	kamf := a.kdf("S(KAMF)", a.KAUSF, 0x6d, canonicalSUPI(string(a.SUPI)), abba)
 
 	a.KAMF = kamf
 	return kamf, nil
 }
 
+// ComputeHXRESStar computes HXRES* from RAND and XRES* (A.5, TS 33.501).
+// It returns an error if XRES* is not 16 bytes long, e.g. not computed yet.
 func (a *Aka) ComputeHXRESStar() ([]byte, error) {
+	if a.av == nil {
+		return nil, ErrNoAuthVectors
+	}
+	resStar := a.av.GetRESStar()
+	if err := ValidateRESStar(resStar); err != nil {
+		return nil, err
+	}
+	a.traceValue("S(HXRES*)", append(append([]byte{}, a.av.GetRAND()...), resStar...))
+	hxresstar := a.hresStar(resStar)
+
+	a.HXRESStar = hxresstar
+	return hxresstar, nil
+}
+
+// hresStar computes HRES* from RAND and resStar (A.5, TS 33.501). It's not traced,
+// so that the input string is traced once by ComputeHXRESStar and not again when
+// RES* from the UE is verified.
+func (a *Aka) hresStar(resStar []byte) []byte {
 	// Construct the input string
-	inputString := append(a.mil.RAND, a.mil.RESStar...)
+	inputString := append(append([]byte{}, a.av.GetRAND()...), resStar...)
 
 	// Compute SHA256
 	hash := sha256.Sum256(inputString)
-	hxresstar := hash[len(hash)-16:]
+	return hash[len(hash)-16:]
+}
 
-	a.HXRESStar = hxresstar
-	return hxresstar, nil
+// UEComputeFromAUTN runs the UE side of 5G AKA on the challenge (RAND and AUTN)
+// received from the network, and returns RES* to be sent back.
+//
+// Unlike the network side, SQN is not known to the UE in advance; it's recovered
+// from AUTN with AK computed from RAND, and MAC-A is verified against it before
+// RES*, KAUSF, KSEAF and KAMF are computed.
+//
+// RES* is computed with the SNN of a as is, so a serving network name with a realm
+// other than 3gppnetwork.org can be used; MCC and MNC are only used if SNN is empty.
+//
+// It returns ErrNot5GChallenge without verifying MAC-A if the separation bit
+// in AMF of AUTN is 0. a should be created with *milenage.Milenage.
+func (a *Aka) UEComputeFromAUTN(rand, autn []byte, mcc, mnc string) ([]byte, error) {
+	if a.av == nil {
+		return nil, ErrNoAuthVectors
+	}
+	mil, ok := a.av.(*milenage.Milenage)
+	if !ok {
+		return nil, fmt.Errorf("UEComputeFromAUTN requires *milenage.Milenage, got: %T", a.av)
+	}
+
+	if len(rand) != 16 {
+		return nil, fmt.Errorf("%w: RAND from the Authentication Request should be %d bytes, got: %d", milenage.ErrInvalidRANDLength, 16, len(rand))
+	}
+	mil.RAND = append([]byte(nil), rand...)
+
+	is5G, err := milenage.CheckSeparationBit(autn)
+	if err != nil {
+		return nil, err
+	}
+	if !is5G {
+		return nil, fmt.Errorf("%w: AMF %x", ErrNot5GChallenge, autn[6:8])
+	}
+
+	if _, err := mil.VerifyAUTN(autn); err != nil {
+		return nil, fmt.Errorf("VerifyAUTN() failed: %w", err)
+	}
+
+	var resStar []byte
+	if len(a.SNN) > 0 {
+		resStar, err = mil.ComputeRESStarSNN(string(a.SNN))
+	} else {
+		resStar, err = mil.ComputeRESStar(mcc, mnc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ComputeRESStar() failed: %w", err)
+	}
+	mil.RESStar = resStar
+
+	if _, err := a.ComputeKAUSF(); err != nil {
+		return nil, fmt.Errorf("ComputeKAUSF() failed: %w", err)
+	}
+	if _, err := a.ComputeKSEAF(); err != nil {
+		return nil, fmt.Errorf("ComputeKSEAF() failed: %w", err)
+	}
+	if _, err := a.ComputeKAMF(); err != nil {
+		return nil, fmt.Errorf("ComputeKAMF() failed: %w", err)
+	}
+
+	return resStar, nil
+}
+
+// ComputeKAUSFFor computes KAUSF in the same way as ComputeKAUSF but with the SQN xor AK
+// given instead of the one from SQN and AK in Milenage, e.g. to compute KAUSF for
+// another challenge without modifying the Milenage shared with other computations.
+//
+// The result is neither stored in a nor persisted in Store.
+func (a *Aka) ComputeKAUSFFor(sqnXorAk []byte) ([]byte, error) {
+	if len(sqnXorAk) != 6 {
+		return nil, fmt.Errorf("length of SQN xor AK should be %d, got: %d", 6, len(sqnXorAk))
+	}
+	if a.av == nil {
+		return nil, ErrNoAuthVectors
+	}
+	return a.deriveKAUSF(sqnXorAk), nil
 }
 
-// takes in a byte array and
-// returns a byte array of length 2 bytes containing the length of the input byte array
-func byteArrayLen2B(b []byte) []byte {
-	length := len(b)
-	result := make([]byte, 2)
-	binary.BigEndian.PutUint16(result, uint16(length))
-	return result
+// deriveKAUSF derives KAUSF from CK||IK with SNN and the SQN xor AK given.
+func (a *Aka) deriveKAUSF(sqnXorAk []byte) []byte {
+	return a.kdf("S(KAUSF)", a.derivationKey(), 0x6a, a.SNN, sqnXorAk)
+}
+
+// sqnXorAK returns SQN xor AK from the AuthVectors.
+func (a *Aka) sqnXorAK() []byte {
+	return milenage.Xor(a.av.GetSQN(), a.av.GetAK())
+}
+
+// derivationKey returns CK || IK from the AuthVectors in a new slice.
+func (a *Aka) derivationKey() []byte {
+	return append(append([]byte{}, a.av.GetCK()...), a.av.GetIK()...)
+}
+
+// kausfInput constructs the input string S to the KAUSF derivation function.
+func (a *Aka) kausfInput(sqnXorAk []byte) []byte {
+	return milenage.KDFInput(0x6a, a.SNN, sqnXorAk)
+}
+
+// kdf derives a key with milenage.KDF using Hash, passing the input string S
+// to trace with name, and truncates it as specified with TruncateKeysTo.
+//
+// It's only for KAUSF, KSEAF and KAMF, to which Hash and TruncateKeysTo apply;
+// the other derivations use milenage.KDF as is.
+func (a *Aka) kdf(name string, key []byte, fc byte, params ...[]byte) []byte {
+	if a.trace != nil {
+		a.traceValue(name, milenage.KDFInput(fc, params...))
+	}
+	return a.truncateKey(milenage.KDFWithHash(a.hash(), key, fc, params...))
+}
+
+// canonicalSUPI returns SUPI in the form used in P0 of the KAMF derivation,
+// i.e. the digits MCC+MNC+MSIN for an IMSI-type SUPI without the "imsi-" prefix
+// used in the SBI (A.7.0, TS 33.501). The "nai-" prefix is stripped likewise.
+func canonicalSUPI(supi string) []byte {
+	for _, prefix := range []string{"imsi-", "nai-"} {
+		if rest, ok := strings.CutPrefix(supi, prefix); ok {
+			return []byte(rest)
+		}
+	}
+	return []byte(supi)
+}
+
+// SetTrace sets the function to be called with the input strings S
+// to the key derivation functions, so that each of them can be cross-checked
+// with TS 33.501 Annex A. Passing nil disables tracing.
+func (a *Aka) SetTrace(fn func(name string, value []byte)) {
+	a.trace = fn
+}
+
+func (a *Aka) traceValue(name string, value []byte) {
+	if a.trace != nil {
+		a.trace(name, value)
+	}
+}
+
+// hash returns Hash, or sha256.New if it's not set.
+func (a *Aka) hash() func() hash.Hash {
+	if a.Hash != nil {
+		return a.Hash
+	}
+	return sha256.New
+}
+
+// truncateKey truncates k as specified with TruncateKeysTo.
+func (a *Aka) truncateKey(k []byte) []byte {
+	if a.TruncateKeysTo > 0 && a.TruncateKeysTo < len(k) {
+		return k[:a.TruncateKeysTo]
+	}
+	return k
 }
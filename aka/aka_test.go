@@ -0,0 +1,346 @@
+package aka
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"5G_AKA/milenage"
+)
+
+// newTestMilenage returns a Milenage with the default values of the command, but AMF.
+func newTestMilenage(tb testing.TB, amf uint16) *milenage.Milenage {
+	tb.Helper()
+	return milenage.NewWithOPc(
+		mustHex(tb, "00112233445566778899aabbccddeeff"),
+		mustHex(tb, "62e75b8d6fa5bf46ec87a9276f9df54d"),
+		mustHex(tb, "00112233445566778899aabbccddeeff"),
+		1, amf)
+}
+
+// challenge returns AUTN generated on the network side with m.
+func challenge(tb testing.TB, m *milenage.Milenage) []byte {
+	tb.Helper()
+	if _, err := m.F1(); err != nil {
+		tb.Fatalf("F1() failed: %v", err)
+	}
+	if _, _, _, _, err := m.F2345(); err != nil {
+		tb.Fatalf("F2345() failed: %v", err)
+	}
+	autn, err := m.GenerateAUTN()
+	if err != nil {
+		tb.Fatalf("GenerateAUTN() failed: %v", err)
+	}
+	return autn
+}
+
+func BenchmarkComputeKAUSF(b *testing.B) {
+	m := newTestMilenage(b, 0x8000)
+	challenge(b, m)
+	a := New(m, testSNN, testSUPI)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.ComputeKAUSF(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNewWithValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		av   AuthVectors
+	}{
+		{"nil", nil},
+		{"nil *Milenage", (*milenage.Milenage)(nil)},
+		{"zero Milenage", &milenage.Milenage{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewWithValidation(tt.av, testSNN, testSUPI)
+			if err == nil {
+				t.Fatalf("NewWithValidation() = %+v, want an error", a)
+			}
+		})
+	}
+}
+
+func TestComputeKAMFWithABBA(t *testing.T) {
+	kausf := mustHex(t, "3b759becc904d5b2aad2fcf15c88ce4354ade608ebbd6d89aa1c3281564c56f8")
+	a, err := NewFromKAUSF(kausf, testSNN, testSUPI)
+	if err != nil {
+		t.Fatalf("NewFromKAUSF() failed: %v", err)
+	}
+
+	initial, err := a.ComputeKAMFInitial()
+	if err != nil {
+		t.Fatalf("ComputeKAMFInitial() failed: %v", err)
+	}
+	kamf, err := a.ComputeKAMF()
+	if err != nil {
+		t.Fatalf("ComputeKAMF() failed: %v", err)
+	}
+	if !bytes.Equal(kamf, initial) {
+		t.Errorf("KAMF with the default ABBA = %x, want %x", kamf, initial)
+	}
+
+	a.ABBA = 0x0001
+	kamf, err = a.ComputeKAMF()
+	if err != nil {
+		t.Fatalf("ComputeKAMF() failed: %v", err)
+	}
+	if bytes.Equal(kamf, initial) {
+		t.Errorf("KAMF with ABBA 0x0001 = %x, want other than the one with ABBAInitialReg", kamf)
+	}
+
+	// ComputeKAMFInitial ignores ABBA
+	again, err := a.ComputeKAMFInitial()
+	if err != nil {
+		t.Fatalf("ComputeKAMFInitial() failed: %v", err)
+	}
+	if !bytes.Equal(again, initial) {
+		t.Errorf("ComputeKAMFInitial() with ABBA 0x0001 = %x, want %x", again, initial)
+	}
+}
+
+func TestUEComputeFromAUTNNot5G(t *testing.T) {
+	hn := newTestMilenage(t, 0x0000)
+	autn := challenge(t, hn)
+
+	ue := New(newTestMilenage(t, 0x8000), testSNN, testSUPI)
+	_, err := ue.UEComputeFromAUTN(hn.RAND, autn, "001", "01")
+	if !errors.Is(err, ErrNot5GChallenge) {
+		t.Fatalf("UEComputeFromAUTN() with AMF 0x0000: err = %v, want %v", err, ErrNot5GChallenge)
+	}
+	if errors.Is(err, milenage.ErrMACMismatch) {
+		t.Errorf("UEComputeFromAUTN() with AMF 0x0000: err = %v, want no MAC mismatch as MAC-A is valid", err)
+	}
+}
+
+func TestStaleAK(t *testing.T) {
+	m := newTestMilenage(t, 0x8000)
+	challenge(t, m)
+	if err := m.CheckAK(); err != nil {
+		t.Fatalf("CheckAK() with a fresh AK failed: %v", err)
+	}
+
+	// a new RAND without running F2345 again
+	m.RAND = mustHex(t, "ffeeddccbbaa99887766554433221100")
+	if err := m.CheckAK(); err == nil {
+		t.Error("CheckAK() = nil after RAND has changed, want an error")
+	}
+	if autn, err := m.GenerateAUTN(); err == nil {
+		t.Errorf("GenerateAUTN() = %x with a stale AK, want an error", autn)
+	}
+	if kausf, err := New(m, testSNN, testSUPI).ComputeKAUSF(); err == nil {
+		t.Errorf("ComputeKAUSF() = %x with a stale AK, want an error", kausf)
+	}
+
+	// F2345 makes AK fresh again
+	if _, _, _, _, err := m.F2345(); err != nil {
+		t.Fatalf("F2345() failed: %v", err)
+	}
+	if err := m.CheckAK(); err != nil {
+		t.Errorf("CheckAK() after F2345() failed: %v", err)
+	}
+}
+
+func TestSEAFChallenge(t *testing.T) {
+	m := newTestMilenage(t, 0x8000)
+	autn := challenge(t, m)
+
+	a := New(m, testSNN, testSUPI)
+	if err := a.SetNgKSI(false, 3); err != nil {
+		t.Fatalf("SetNgKSI() failed: %v", err)
+	}
+	a.ABBA = 0x0001
+
+	c, err := a.SEAFChallenge(autn)
+	if err != nil {
+		t.Fatalf("SEAFChallenge() failed: %v", err)
+	}
+	if !bytes.Equal(c.RAND, m.RAND) {
+		t.Errorf("RAND = %x, want %x", c.RAND, m.RAND)
+	}
+	if !bytes.Equal(c.AUTN, autn) {
+		t.Errorf("AUTN = %x, want %x", c.AUTN, autn)
+	}
+	if c.NgKSI != 0x03 {
+		t.Errorf("ngKSI = %#02x, want 0x03", c.NgKSI)
+	}
+	if want := []byte{0x00, 0x01}; !bytes.Equal(c.ABBA, want) {
+		t.Errorf("ABBA = %x, want %x", c.ABBA, want)
+	}
+
+	if _, err := a.SEAFChallenge(autn[:15]); err == nil {
+		t.Error("SEAFChallenge() with a 15-byte AUTN = nil, want an error")
+	}
+}
+
+func TestNewFromKAUSF(t *testing.T) {
+	r, err := RunFlow(FlowParams{
+		IMSI: testSUPI,
+		MCC:  "001",
+		MNC:  "01",
+		K:    mustHex(t, "00112233445566778899aabbccddeeff"),
+		OPc:  mustHex(t, "62e75b8d6fa5bf46ec87a9276f9df54d"),
+		SQN:  1,
+		AMF:  0x8000,
+		RAND: mustHex(t, "00112233445566778899aabbccddeeff"),
+	})
+	if err != nil {
+		t.Fatalf("RunFlow() failed: %v", err)
+	}
+
+	// resume from KAUSF stored by the AUSF
+	a, err := NewFromKAUSF(r.KAUSF, testSNN, testSUPI)
+	if err != nil {
+		t.Fatalf("NewFromKAUSF() failed: %v", err)
+	}
+	kseaf, err := a.ComputeKSEAF()
+	if err != nil {
+		t.Fatalf("ComputeKSEAF() failed: %v", err)
+	}
+	if !bytes.Equal(kseaf, r.KSEAF) {
+		t.Errorf("KSEAF = %x, want %x", kseaf, r.KSEAF)
+	}
+	kamf, err := a.ComputeKAMF()
+	if err != nil {
+		t.Fatalf("ComputeKAMF() failed: %v", err)
+	}
+	if !bytes.Equal(kamf, r.KAMF) {
+		t.Errorf("KAMF = %x, want %x", kamf, r.KAMF)
+	}
+
+	// the ones that need the values from f1-f5 fail cleanly
+	a.HXRESStar = r.HXRESStar
+	for name, f := range map[string]func() error{
+		"ComputeKAUSF": func() error { _, err := a.ComputeKAUSF(); return err },
+		"ComputeKAUSFFor": func() error {
+			_, err := a.ComputeKAUSFFor(r.AUTN[:6])
+			return err
+		},
+		"ComputeHXRESStar": func() error { _, err := a.ComputeHXRESStar(); return err },
+		"VerifyHXRESStar": func() error {
+			_, err := a.VerifyHXRESStar(r.RESStar)
+			return err
+		},
+		"UEComputeFromAUTN": func() error {
+			_, err := a.UEComputeFromAUTN(mustHex(t, "00112233445566778899aabbccddeeff"), r.AUTN, "001", "01")
+			return err
+		},
+		"SEAFChallenge": func() error { _, err := a.SEAFChallenge(r.AUTN); return err },
+	} {
+		if err := f(); !errors.Is(err, ErrNoAuthVectors) {
+			t.Errorf("%s(): err = %v, want %v", name, err, ErrNoAuthVectors)
+		}
+	}
+
+	if _, err := NewFromKAUSF(r.KAUSF[:16], testSNN, testSUPI); !errors.Is(err, milenage.ErrInvalidKeyLength) {
+		t.Errorf("NewFromKAUSF() with a 16-byte KAUSF: err = %v, want %v", err, milenage.ErrInvalidKeyLength)
+	}
+}
+
+func TestAKIDRealm(t *testing.T) {
+	kausf := mustHex(t, "3b759becc904d5b2aad2fcf15c88ce4354ade608ebbd6d89aa1c3281564c56f8")
+	tests := []struct {
+		name, supi, realm string
+	}{
+		{"IMSI", "imsi-310260123456789", "mnc260.mcc310.3gppnetwork.org"},
+		{"2-digit MNC", "001010123456789", "mnc001.mcc001.3gppnetwork.org"},
+		{"NAI", "nai-user@example.com", "example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// roaming in a visited network other than the home network of SUPI
+			a, err := NewFromKAUSF(kausf, "5G:mnc093.mcc208.3gppnetwork.org", tt.supi)
+			if err != nil {
+				t.Fatalf("NewFromKAUSF() failed: %v", err)
+			}
+			akid := a.AKID()
+			if _, realm, _ := strings.Cut(akid, "@"); realm != tt.realm {
+				t.Errorf("AKID() = %s, want the realm %s", akid, tt.realm)
+			}
+		})
+	}
+
+	a, err := NewFromKAUSF(kausf, testSNN, "nai-user")
+	if err != nil {
+		t.Fatalf("NewFromKAUSF() failed: %v", err)
+	}
+	if akid := a.AKID(); akid != "" {
+		t.Errorf("AKID() for a NAI without a realm = %s, want empty", akid)
+	}
+}
+
+func TestKeysIgnoreSUPIPrefix(t *testing.T) {
+	kausf := mustHex(t, "3b759becc904d5b2aad2fcf15c88ce4354ade608ebbd6d89aa1c3281564c56f8")
+	keys := func(supi string) (kamf, kakma, atid []byte) {
+		t.Helper()
+		a, err := NewFromKAUSF(kausf, testSNN, supi)
+		if err != nil {
+			t.Fatalf("NewFromKAUSF() failed: %v", err)
+		}
+		if kamf, err = a.ComputeKAMF(); err != nil {
+			t.Fatalf("ComputeKAMF() failed: %v", err)
+		}
+		if kakma, err = a.ComputeKAKMA(); err != nil {
+			t.Fatalf("ComputeKAKMA() failed: %v", err)
+		}
+		if atid, err = a.ComputeATID(); err != nil {
+			t.Fatalf("ComputeATID() failed: %v", err)
+		}
+		return kamf, kakma, atid
+	}
+
+	kamf, kakma, atid := keys("imsi-" + testSUPI)
+	wantKAMF, wantKAKMA, wantATID := keys(testSUPI)
+	if !bytes.Equal(kamf, wantKAMF) {
+		t.Errorf("KAMF with imsi- = %x, want %x", kamf, wantKAMF)
+	}
+	if !bytes.Equal(kakma, wantKAKMA) {
+		t.Errorf("KAKMA with imsi- = %x, want %x", kakma, wantKAKMA)
+	}
+	if !bytes.Equal(atid, wantATID) {
+		t.Errorf("A-TID with imsi- = %x, want %x", atid, wantATID)
+	}
+}
+
+func TestFingerprints(t *testing.T) {
+	kausf := mustHex(t, "3b759becc904d5b2aad2fcf15c88ce4354ade608ebbd6d89aa1c3281564c56f8")
+	a, err := NewFromKAUSF(kausf, testSNN, testSUPI)
+	if err != nil {
+		t.Fatalf("NewFromKAUSF() failed: %v", err)
+	}
+	a.KAMF = make([]byte, 32)
+
+	fps := a.Fingerprints()
+	if _, ok := fps["KAUSF"]; !ok || len(fps) != 1 {
+		t.Errorf("Fingerprints() before the keys are computed = %v, want only KAUSF", fps)
+	}
+
+	if _, err := a.ComputeKSEAF(); err != nil {
+		t.Fatalf("ComputeKSEAF() failed: %v", err)
+	}
+	if _, err := a.ComputeKAMF(); err != nil {
+		t.Fatalf("ComputeKAMF() failed: %v", err)
+	}
+	fps = a.Fingerprints()
+	for _, k := range []struct {
+		name string
+		key  []byte
+	}{
+		{"KAUSF", a.KAUSF},
+		{"KSEAF", a.KSEAF},
+		{"KAMF", a.KAMF},
+	} {
+		sum := sha256.Sum256(k.key)
+		if want := hex.EncodeToString(sum[:4]); fps[k.name] != want {
+			t.Errorf("Fingerprints()[%s] = %q, want %q", k.name, fps[k.name], want)
+		}
+	}
+}
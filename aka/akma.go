@@ -0,0 +1,166 @@
+package aka
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"5G_AKA/milenage"
+)
+
+// KAUSFStore persists KAUSF after the authentication, so that it can be used later
+// by the features such as AKMA and Steering of Roaming.
+type KAUSFStore interface {
+	Get(supi string) ([]byte, error)
+	Set(supi string, kausf []byte) error
+}
+
+// MemoryKAUSFStore is a KAUSFStore that keeps KAUSF in memory.
+// It's safe for concurrent use.
+type MemoryKAUSFStore struct {
+	mu sync.RWMutex
+	m  map[string][]byte
+}
+
+// NewMemoryKAUSFStore creates an empty MemoryKAUSFStore.
+func NewMemoryKAUSFStore() *MemoryKAUSFStore {
+	return &MemoryKAUSFStore{m: make(map[string][]byte)}
+}
+
+// Get returns the KAUSF stored for the SUPI.
+func (s *MemoryKAUSFStore) Get(supi string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	kausf, ok := s.m[supi]
+	if !ok {
+		return nil, fmt.Errorf("no KAUSF found for SUPI: %s", supi)
+	}
+	return append([]byte{}, kausf...), nil
+}
+
+// Set stores KAUSF for the SUPI, replacing the existing one if any.
+func (s *MemoryKAUSFStore) Set(supi string, kausf []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m[supi] = append([]byte{}, kausf...)
+	return nil
+}
+
+// ComputeKAKMA computes KAKMA from KAUSF as described in A.2, TS 33.535.
+// SUPI goes into P1 as returned by canonicalSUPI, as in ComputeKAMF.
+//
+// If Store is set, KAUSF is fetched from it by SUPI, as in the AAnF that
+// receives KAUSF from the AUSF. Otherwise the KAUSF in a is used.
+func (a *Aka) ComputeKAKMA() ([]byte, error) {
+	kausf, err := a.akmaKAUSF()
+	if err != nil {
+		return nil, err
+	}
+
+	label := []byte("AKMA")
+	supi := canonicalSUPI(string(a.SUPI))
+	a.traceValue("S(KAKMA)", milenage.KDFInput(0x80, label, supi))
+	kakma := milenage.KDF(kausf, 0x80, label, supi)
+
+	a.KAKMA = kakma
+	return kakma, nil
+}
+
+// ComputeATID computes A-TID, the AKMA temporary UE identifier, from KAUSF
+// as described in A.3, TS 33.535. KAUSF is taken in the same way as ComputeKAKMA.
+func (a *Aka) ComputeATID() ([]byte, error) {
+	kausf, err := a.akmaKAUSF()
+	if err != nil {
+		return nil, err
+	}
+
+	label := []byte("A-TID")
+	supi := canonicalSUPI(string(a.SUPI))
+	a.traceValue("S(A-TID)", milenage.KDFInput(0x81, label, supi))
+	atid := milenage.KDF(kausf, 0x81, label, supi)
+
+	a.ATID = atid
+	return atid, nil
+}
+
+// AKID returns A-KID, the AKMA key identifier, in the NAI format (username@realm)
+// described in 6.1, TS 33.535. The username consists of RID and base64-encoded A-TID,
+// and the realm identifies the home network of SUPI, i.e. its MCC and MNC for an IMSI
+// or the realm of a NAI, not the serving network, which differs when roaming.
+//
+// A-TID is computed with ComputeATID if not yet done. An empty string is returned
+// if it fails or the home network can't be taken from SUPI.
+func (a *Aka) AKID() string {
+	if a.ATID == nil {
+		if _, err := a.ComputeATID(); err != nil {
+			return ""
+		}
+	}
+
+	realm, err := homeRealm(string(a.SUPI))
+	if err != nil {
+		return ""
+	}
+	rid := a.RID
+	if rid == "" {
+		rid = "0"
+	}
+
+	return fmt.Sprintf("%s.%s@%s", rid, base64.StdEncoding.EncodeToString(a.ATID), realm)
+}
+
+// ComputeKAF computes KAF for the Application Function identified by afID
+// from KAKMA as described in A.4, TS 33.535.
+//
+// KAKMA is computed with ComputeKAKMA if not yet done.
+func (a *Aka) ComputeKAF(afID string) ([]byte, error) {
+	if a.KAKMA == nil {
+		if _, err := a.ComputeKAKMA(); err != nil {
+			return nil, fmt.Errorf("ComputeKAKMA() failed: %w", err)
+		}
+	}
+
+	p0 := []byte(afID)
+	a.traceValue("S(KAF)", milenage.KDFInput(0x82, p0))
+	kaf := milenage.KDF(a.KAKMA, 0x82, p0)
+
+	return kaf, nil
+}
+
+// homeRealm returns the realm of the home network of supi: the one of
+// the NAI for a NAI, otherwise "mnc<MNC>.mcc<MCC>.3gppnetwork.org" of the IMSI.
+func homeRealm(supi string) (string, error) {
+	if nai, ok := strings.CutPrefix(supi, "nai-"); ok {
+		_, realm, ok := strings.Cut(nai, "@")
+		if !ok || realm == "" {
+			return "", fmt.Errorf("NAI has no realm: %s", nai)
+		}
+		return realm, nil
+	}
+
+	imsi, err := ParseIMSI(string(canonicalSUPI(supi)))
+	if err != nil {
+		return "", err
+	}
+	snn, err := milenage.ServingNetworkName(imsi.MCC, imsi.MNC)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(snn, "5G:"), nil
+}
+
+// akmaKAUSF returns KAUSF from Store if set, otherwise the one in a.
+func (a *Aka) akmaKAUSF() ([]byte, error) {
+	if a.Store == nil {
+		return a.KAUSF, nil
+	}
+
+	kausf, err := a.Store.Get(string(a.SUPI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get KAUSF: %w", err)
+	}
+	return kausf, nil
+}
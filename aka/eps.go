@@ -0,0 +1,101 @@
+package aka
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// ComputeKASME computes KASME for EPS AKA (A.2, TS 33.401) from CK, IK,
+// the serving network ID (i.e. the PLMN ID encoded in 3 bytes) and SQN xor AK.
+func ComputeKASME(ck, ik, snID, sqnXorAk []byte) ([]byte, error) {
+	if len(ck) != 16 {
+		return nil, fmt.Errorf("%w: length of CK should be %d, got: %d", milenage.ErrInvalidKeyLength, 16, len(ck))
+	}
+	if len(ik) != 16 {
+		return nil, fmt.Errorf("%w: length of IK should be %d, got: %d", milenage.ErrInvalidKeyLength, 16, len(ik))
+	}
+	if len(snID) != 3 {
+		return nil, fmt.Errorf("length of SN ID should be %d, got: %d", 3, len(snID))
+	}
+	if len(sqnXorAk) != 6 {
+		return nil, fmt.Errorf("length of SQN xor AK should be %d, got: %d", 6, len(sqnXorAk))
+	}
+
+	inputKey := append(append([]byte{}, ck...), ik...)
+	return milenage.KDF(inputKey, 0x10, snID, sqnXorAk), nil
+}
+
+// EncodePLMNID encodes MCC and MNC into the 3-byte PLMN ID (10.5.1.13, TS 24.008),
+// which is used as the serving network ID in the derivation of KASME. A 2-digit
+// MNC is encoded with the filler 0xf in place of its third digit.
+func EncodePLMNID(mcc, mnc string) ([]byte, error) {
+	// MCC and MNC are validated in the same way as for the serving network name
+	if _, err := milenage.ServingNetworkName(mcc, mnc); err != nil {
+		return nil, err
+	}
+
+	mnc3 := byte(0x0f)
+	if len(mnc) == 3 {
+		mnc3 = mnc[2] - '0'
+	}
+	return []byte{
+		(mcc[1]-'0')<<4 | (mcc[0] - '0'),
+		mnc3<<4 | (mcc[2] - '0'),
+		(mnc[1]-'0')<<4 | (mnc[0] - '0'),
+	}, nil
+}
+
+// EPSAuthVector is an EPS authentication vector returned by the HSS to the MME
+// (6.1.1, TS 33.401).
+type EPSAuthVector struct {
+	RAND  []byte
+	XRES  []byte
+	AUTN  []byte
+	KASME []byte
+}
+
+type epsAuthVectorJSON struct {
+	RAND  milenage.HexBytes `json:"rand"`
+	XRES  milenage.HexBytes `json:"xres"`
+	AUTN  milenage.HexBytes `json:"autn"`
+	KASME milenage.HexBytes `json:"kasme"`
+}
+
+// MarshalJSON encodes the vector with the values in hex.
+func (v EPSAuthVector) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&epsAuthVectorJSON{
+		RAND:  v.RAND,
+		XRES:  v.XRES,
+		AUTN:  v.AUTN,
+		KASME: v.KASME,
+	})
+}
+
+// ComputeEPSAuthVector computes an EPS authentication vector with MILENAGE for the
+// serving network of MCC and MNC, i.e. without the 5G-specific RES*, KAUSF and KSEAF.
+//
+// The separation bit of AMF should be 1 for EPS (Annex H, TS 33.102).
+func ComputeEPSAuthVector(k, opc, rand []byte, sqn uint64, amf uint16, mcc, mnc string) (*EPSAuthVector, error) {
+	snID, err := EncodePLMNID(mcc, mnc)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := milenage.ComputeVector(k, opc, rand, sqn, amf)
+	if err != nil {
+		return nil, err
+	}
+	kasme, err := ComputeKASME(v.CK, v.IK, snID, v.AUTN[0:6])
+	if err != nil {
+		return nil, fmt.Errorf("ComputeKASME() failed: %w", err)
+	}
+
+	return &EPSAuthVector{
+		RAND:  v.RAND,
+		XRES:  v.XRES,
+		AUTN:  v.AUTN,
+		KASME: kasme,
+	}, nil
+}
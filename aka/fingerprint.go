@@ -0,0 +1,28 @@
+package aka
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Fingerprints returns the fingerprints of the keys in a, i.e. the first 8 hex digits of
+// SHA-256 of each of them indexed by the name, so that they can be logged and compared
+// across runs without leaking the keys themselves. Keys that are not computed yet,
+// i.e. empty or all zero, are omitted.
+func (a *Aka) Fingerprints() map[string]string {
+	fps := make(map[string]string, 4)
+	for name, k := range map[string][]byte{
+		"KAUSF":     a.KAUSF,
+		"KSEAF":     a.KSEAF,
+		"KAMF":      a.KAMF,
+		"HXRESStar": a.HXRESStar,
+	} {
+		if len(k) == 0 || bytes.Count(k, []byte{0}) == len(k) {
+			continue
+		}
+		sum := sha256.Sum256(k)
+		fps[name] = hex.EncodeToString(sum[:4])
+	}
+	return fps
+}
@@ -0,0 +1,144 @@
+package aka
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"5G_AKA/milenage"
+)
+
+// Fixture is the inputs and outputs of a complete authentication run by RunFlow,
+// to be shared with downstream projects as a test fixture.
+type Fixture struct {
+	IMSI string
+	MCC  string
+	MNC  string
+	K    []byte
+	OP   []byte
+	SQN  uint64
+	AMF  uint16
+	RAND []byte
+
+	Result *FlowResult
+}
+
+type fixtureJSON struct {
+	IMSI string            `json:"imsi"`
+	MCC  string            `json:"mcc"`
+	MNC  string            `json:"mnc"`
+	K    milenage.HexBytes `json:"k"`
+	OP   milenage.HexBytes `json:"op"`
+	SQN  uint64            `json:"sqn"`
+	AMF  uint16            `json:"amf"`
+	RAND milenage.HexBytes `json:"rand"`
+
+	SNN       string            `json:"snn"`
+	OPc       milenage.HexBytes `json:"opc"`
+	MACA      milenage.HexBytes `json:"maca"`
+	CK        milenage.HexBytes `json:"ck"`
+	IK        milenage.HexBytes `json:"ik"`
+	AK        milenage.HexBytes `json:"ak"`
+	XRES      milenage.HexBytes `json:"xres"`
+	XRESStar  milenage.HexBytes `json:"xresStar"`
+	AUTN      milenage.HexBytes `json:"autn"`
+	KAUSF     milenage.HexBytes `json:"kausf"`
+	HXRESStar milenage.HexBytes `json:"hxresStar"`
+	KSEAF     milenage.HexBytes `json:"kseaf"`
+	KAMF      milenage.HexBytes `json:"kamf"`
+	RESStar   milenage.HexBytes `json:"resStar"`
+}
+
+// MarshalJSON encodes the fixture into a flat JSON object with the values in hex.
+func (f Fixture) MarshalJSON() ([]byte, error) {
+	r := f.Result
+	if r == nil {
+		r = &FlowResult{}
+	}
+	return json.Marshal(&fixtureJSON{
+		IMSI: f.IMSI,
+		MCC:  f.MCC,
+		MNC:  f.MNC,
+		K:    f.K,
+		OP:   f.OP,
+		SQN:  f.SQN,
+		AMF:  f.AMF,
+		RAND: f.RAND,
+
+		SNN:       r.SNN,
+		OPc:       r.OPc,
+		MACA:      r.MACA,
+		CK:        r.CK,
+		IK:        r.IK,
+		AK:        r.AK,
+		XRES:      r.XRES,
+		XRESStar:  r.XRESStar,
+		AUTN:      r.AUTN,
+		KAUSF:     r.KAUSF,
+		HXRESStar: r.HXRESStar,
+		KSEAF:     r.KSEAF,
+		KAMF:      r.KAMF,
+		RESStar:   r.RESStar,
+	})
+}
+
+// GenerateFixtures runs the flow count times with the inputs derived from seed,
+// and returns them with the results. The same seed always gives the same fixtures.
+//
+// The subscribers are in the test PLMN 001-01 with AMF 0x8000.
+func GenerateFixtures(seed []byte, count int) ([]Fixture, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("count should not be negative, got: %d", count)
+	}
+
+	fixtures := make([]Fixture, count)
+	for i := range fixtures {
+		derive := func(label string) []byte {
+			h := sha256.New()
+			h.Write(seed)
+			h.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+			h.Write([]byte(label))
+			return h.Sum(nil)
+		}
+
+		f := Fixture{
+			IMSI: fmt.Sprintf("00101%010d", binary.BigEndian.Uint64(derive("MSIN")[:8])%10000000000),
+			MCC:  "001",
+			MNC:  "01",
+			K:    derive("K")[:16],
+			OP:   derive("OP")[:16],
+			SQN:  binary.BigEndian.Uint64(derive("SQN")[:8]) >> 16,
+			AMF:  0x8000,
+			RAND: derive("RAND")[:16],
+		}
+
+		r, err := RunFlow(FlowParams{
+			IMSI: f.IMSI,
+			MCC:  f.MCC,
+			MNC:  f.MNC,
+			K:    f.K,
+			OP:   f.OP,
+			SQN:  f.SQN,
+			AMF:  f.AMF,
+			RAND: f.RAND,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate fixture #%d: %w", i, err)
+		}
+		f.Result = r
+		fixtures[i] = f
+	}
+	return fixtures, nil
+}
+
+// ExportFixtures writes fixtures to w as an indented JSON array.
+func ExportFixtures(w io.Writer, fixtures []Fixture) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(fixtures); err != nil {
+		return fmt.Errorf("failed to encode fixtures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package aka
+
+import (
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// FlowParams is a set of inputs to RunFlow.
+type FlowParams struct {
+	// IMSI is used as SUPI, and MCC and MNC are the ones of the serving network.
+	IMSI string
+	MCC  string
+	MNC  string
+
+	// SNN overrides the serving network name built from MCC and MNC if set,
+	// e.g. for a lab network with a realm other than 3gppnetwork.org.
+	SNN string
+
+	// Either OP or OPc should be given. If OPc is nil, it's computed from K and OP.
+	K   []byte
+	OP  []byte
+	OPc []byte
+
+	SQN  uint64
+	AMF  uint16
+	RAND []byte
+
+	// Trace is set to both Milenage and Aka if not nil. See SetTrace.
+	Trace func(name string, value []byte)
+}
+
+// FlowResult is a set of values computed at each node during RunFlow.
+type FlowResult struct {
+	SNN string
+	OPc []byte
+
+	// computed at UDM
+	MACA     []byte
+	CK       []byte
+	IK       []byte
+	AK       []byte
+	XRES     []byte
+	XRESStar []byte
+	AUTN     []byte
+	KAUSF    []byte
+
+	// computed at AUSF
+	HXRESStar []byte
+	KSEAF     []byte
+
+	// computed at SEAF
+	KAMF []byte
+
+	// computed at UE
+	RESStar []byte
+	UEKAMF  []byte
+}
+
+// RunFlow runs the whole 5G AKA flow (TS 33.501 6.1.3.2) from the UDM
+// through the AUSF and SEAF to the UE, and returns all the values computed.
+//
+// It returns an error if the UE rejects the challenge or the response
+// doesn't match on the network side.
+//
+// RunFlow is the facade that guarantees the ordering of the steps, e.g. F2345 runs
+// before both GenerateAUTN and ComputeKAUSF so that they use the same fresh AK.
+func RunFlow(p FlowParams) (*FlowResult, error) {
+	var err error
+	snn := p.SNN
+	if snn == "" {
+		snn, err = milenage.ServingNetworkName(p.MCC, p.MNC)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r := &FlowResult{
+		SNN: snn,
+		OPc: p.OPc,
+	}
+
+	if r.OPc == nil {
+		opc, err := milenage.ComputeOPc(p.K, p.OP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute OPc: %w", err)
+		}
+		r.OPc = opc
+	}
+
+	// UDM
+	m := milenage.NewWithOPc(p.K, r.OPc, p.RAND, p.SQN, p.AMF)
+	m.SetTrace(p.Trace)
+
+	r.MACA, err = m.F1()
+	if err != nil {
+		return nil, fmt.Errorf("F1() failed: %w", err)
+	}
+	r.XRES, r.CK, r.IK, r.AK, err = m.F2345()
+	if err != nil {
+		return nil, fmt.Errorf("F2345() failed: %w", err)
+	}
+	m.RESStar, err = m.ComputeRESStarSNN(r.SNN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute RESStar: %w", err)
+	}
+	r.XRESStar = m.RESStar
+	r.AUTN, err = m.GenerateAUTN()
+	if err != nil {
+		return nil, fmt.Errorf("GenerateAUTN() failed: %w", err)
+	}
+
+	a := New(m, r.SNN, p.IMSI)
+	a.SetTrace(p.Trace)
+	r.KAUSF, err = a.ComputeKAUSF()
+	if err != nil {
+		return nil, fmt.Errorf("ComputeKAUSF() failed: %w", err)
+	}
+
+	// AUSF
+	r.HXRESStar, err = a.ComputeHXRESStar()
+	if err != nil {
+		return nil, fmt.Errorf("ComputeHXRESStar() failed: %w", err)
+	}
+
+	// UE
+	ue := New(milenage.NewWithOPc(p.K, r.OPc, nil, 0, 0), r.SNN, p.IMSI)
+	r.RESStar, err = ue.UEComputeFromAUTN(p.RAND, r.AUTN, p.MCC, p.MNC)
+	if err != nil {
+		return nil, fmt.Errorf("UE rejected the challenge: %w", err)
+	}
+	r.UEKAMF = ue.KAMF
+
+	// SEAF verifies HRES* of RES* from the UE, then AUSF verifies RES* itself
+	ok, err := a.VerifyHXRESStar(r.RESStar)
+	if err != nil {
+		return nil, fmt.Errorf("VerifyHXRESStar() failed: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("HRES* mismatch: expected %x", r.HXRESStar)
+	}
+	if !VerifyRESStar(r.XRESStar, r.RESStar) {
+		return nil, fmt.Errorf("RES* mismatch: expected %x, got %x", r.XRESStar, r.RESStar)
+	}
+
+	r.KSEAF, err = a.ComputeKSEAF()
+	if err != nil {
+		return nil, fmt.Errorf("ComputeKSEAF() failed: %w", err)
+	}
+
+	// SEAF
+	r.KAMF, err = a.ComputeKAMF()
+	if err != nil {
+		return nil, fmt.Errorf("ComputeKAMF() failed: %w", err)
+	}
+
+	return r, nil
+}
+
+// HEAuthVector is a 5G Home Environment Authentication Vector
+// returned by the UDM to the AUSF (6.1.3.2, TS 33.501).
+type HEAuthVector struct {
+	RAND     []byte
+	AUTN     []byte
+	XRESStar []byte
+	KAUSF    []byte
+}
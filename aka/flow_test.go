@@ -0,0 +1,127 @@
+package aka
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"5G_AKA/milenage"
+)
+
+const (
+	testSNN  = "5G:mnc001.mcc001.3gppnetwork.org"
+	testSUPI = "001010123456789"
+)
+
+// mustHex decodes s in hex, failing the test if it's malformed.
+func mustHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		tb.Fatalf("hex.DecodeString(%q) failed: %v", s, err)
+	}
+	return b
+}
+
+// TestRunFlowAgreesWithUE runs the network side with RunFlow and the UE side
+// separately with UEComputeFromAUTN on the same inputs, and checks that both ends
+// agree on RES* and all the keys.
+func TestRunFlowAgreesWithUE(t *testing.T) {
+	k := mustHex(t, "00112233445566778899aabbccddeeff")
+	opc := mustHex(t, "62e75b8d6fa5bf46ec87a9276f9df54d")
+	rand := mustHex(t, "00112233445566778899aabbccddeeff")
+
+	for _, sqn := range []uint64{1, 0x20, 0xff9bb4d0b607} {
+		r, err := RunFlow(FlowParams{
+			IMSI: testSUPI,
+			MCC:  "001",
+			MNC:  "01",
+			K:    k,
+			OPc:  opc,
+			SQN:  sqn,
+			AMF:  0x8000,
+			RAND: rand,
+		})
+		if err != nil {
+			t.Fatalf("RunFlow() with SQN %x failed: %v", sqn, err)
+		}
+
+		ue := New(milenage.NewWithOPc(k, opc, nil, 0, 0), r.SNN, testSUPI)
+		resStar, err := ue.UEComputeFromAUTN(rand, r.AUTN, "001", "01")
+		if err != nil {
+			t.Fatalf("UEComputeFromAUTN() with SQN %x failed: %v", sqn, err)
+		}
+
+		for _, v := range []struct {
+			name   string
+			hn, ue []byte
+		}{
+			{"RES*", r.XRESStar, resStar},
+			{"KAUSF", r.KAUSF, ue.KAUSF},
+			{"KSEAF", r.KSEAF, ue.KSEAF},
+			{"KAMF", r.KAMF, ue.KAMF},
+		} {
+			if len(v.hn) == 0 || !bytes.Equal(v.hn, v.ue) {
+				t.Errorf("SQN %x: %s = %x on the network side, %x on the UE", sqn, v.name, v.hn, v.ue)
+			}
+		}
+	}
+}
+
+func ExampleRunFlow() {
+	k, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+	opc, _ := hex.DecodeString("62e75b8d6fa5bf46ec87a9276f9df54d")
+	rand, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+
+	r, err := RunFlow(FlowParams{
+		IMSI: "001010123456789",
+		MCC:  "001",
+		MNC:  "01",
+		K:    k,
+		OPc:  opc,
+		SQN:  1,
+		AMF:  0x8000,
+		RAND: rand,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Printf("AUTN:  %x\n", r.AUTN)
+	fmt.Printf("RES*:  %x\n", r.RESStar)
+	fmt.Printf("KAUSF: %x\n", r.KAUSF)
+	fmt.Printf("KSEAF: %x\n", r.KSEAF)
+	fmt.Printf("KAMF:  %x\n", r.KAMF)
+	// Output:
+	// AUTN:  de656c8b0bcf80004af30b82a8531115
+	// RES*:  31b6d938a5290ccc65bc829f9820a8d9
+	// KAUSF: 3b759becc904d5b2aad2fcf15c88ce4354ade608ebbd6d89aa1c3281564c56f8
+	// KSEAF: a1ca0731bbc80913ea613972c75e2782d02b7a13c0b235c98cc5778e4520b944
+	// KAMF:  c0d31ff6197fc31267b4a0a38790347ce5a74268114d53638c3db3d0be19a111
+}
+
+func TestGenerateFixtures(t *testing.T) {
+	seed := []byte("seed")
+	a, err := GenerateFixtures(seed, 2)
+	if err != nil {
+		t.Fatalf("GenerateFixtures() failed: %v", err)
+	}
+	b, err := GenerateFixtures(seed, 2)
+	if err != nil {
+		t.Fatalf("GenerateFixtures() failed: %v", err)
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Result.KAMF, b[i].Result.KAMF) {
+			t.Errorf("KAMF of fixture #%d = %x and %x with the same seed", i, a[i].Result.KAMF, b[i].Result.KAMF)
+		}
+	}
+	if bytes.Equal(a[0].RAND, a[1].RAND) {
+		t.Errorf("fixtures #0 and #1 share RAND %x", a[0].RAND)
+	}
+
+	if f, err := GenerateFixtures(seed, -1); err == nil {
+		t.Errorf("GenerateFixtures(-1) = %d fixtures, want an error", len(f))
+	}
+}
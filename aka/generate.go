@@ -0,0 +1,51 @@
+package aka
+
+import (
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// GenerateVectors generates n 5G HE AVs for the serving network snn with random RANDs,
+// e.g. to load test an AUSF. SQN starts from startSQN and is incremented by
+// milenage.SQNStep for each vector.
+//
+// Unlike milenage.Vector, each HEAuthVector carries XRES* and KAUSF, which are bound
+// to the serving network, hence snn is taken in addition to the subscriber's keys.
+//
+// The vectors are generated with GenerateVectorSeries, so the series is checked
+// with milenage.CheckNoReplays, and XRES* and KAUSF are derived from the CK, IK
+// and AK in it without running MILENAGE again.
+func GenerateVectors(k, opc []byte, amf uint16, startSQN uint64, n int, snn string) ([]HEAuthVector, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("number of vectors should not be negative, got: %d", n)
+	}
+
+	series, err := milenage.NewWithOPc(k, opc, nil, startSQN&0xffffffffffff, amf).GenerateVectorSeries(n)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateVectorSeries() failed: %w", err)
+	}
+
+	vectors := make([]HEAuthVector, n)
+	for i, v := range series {
+		m := milenage.NewWithOPc(k, opc, v.RAND, 0, amf)
+		m.SQN, m.MACA, m.RES, m.CK, m.IK, m.AK = v.SQN, v.MACA, v.XRES, v.CK, v.IK, v.AK
+
+		m.RESStar, err = m.ComputeRESStarSNN(snn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute XRES* of vector #%d: %w", i, err)
+		}
+		kausf, err := New(m, snn, "").ComputeKAUSF()
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute KAUSF of vector #%d: %w", i, err)
+		}
+
+		vectors[i] = HEAuthVector{
+			RAND:     v.RAND,
+			AUTN:     v.AUTN,
+			XRESStar: m.RESStar,
+			KAUSF:    kausf,
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,72 @@
+package aka
+
+import (
+	"bytes"
+	"testing"
+
+	"5G_AKA/milenage"
+)
+
+func TestGenerateVectors(t *testing.T) {
+	k := mustHex(t, "00112233445566778899aabbccddeeff")
+	opc := mustHex(t, "62e75b8d6fa5bf46ec87a9276f9df54d")
+
+	vectors, err := GenerateVectors(k, opc, 0x8000, 1, 4, testSNN)
+	if err != nil {
+		t.Fatalf("GenerateVectors() failed: %v", err)
+	}
+	if len(vectors) != 4 {
+		t.Fatalf("got %d vectors, want 4", len(vectors))
+	}
+
+	for i, v := range vectors {
+		ue := milenage.NewWithOPc(k, opc, nil, 0, 0)
+		a := New(ue, testSNN, "")
+		resStar, err := a.UEComputeFromAUTN(v.RAND, v.AUTN, "", "")
+		if err != nil {
+			t.Fatalf("vector #%d rejected by the UE: %v", i, err)
+		}
+		if want := uint64(1 + i*milenage.SQNStep); ue.SQN[5] != byte(want) {
+			t.Errorf("SQN of vector #%d = %x, want %012x", i, ue.SQN, want)
+		}
+		if !bytes.Equal(resStar, v.XRESStar) {
+			t.Errorf("RES* of vector #%d = %x, want %x", i, resStar, v.XRESStar)
+		}
+		if !bytes.Equal(a.KAUSF, v.KAUSF) {
+			t.Errorf("KAUSF of vector #%d = %x, want %x", i, a.KAUSF, v.KAUSF)
+		}
+
+		// recomputed from scratch with the SQN and RAND of the vector
+		r, err := RunFlow(FlowParams{
+			SNN:  testSNN,
+			K:    k,
+			OPc:  opc,
+			SQN:  uint64(1 + i*milenage.SQNStep),
+			AMF:  0x8000,
+			RAND: v.RAND,
+		})
+		if err != nil {
+			t.Fatalf("RunFlow() for vector #%d failed: %v", i, err)
+		}
+		for _, f := range []struct {
+			name      string
+			got, want []byte
+		}{
+			{"AUTN", v.AUTN, r.AUTN},
+			{"XRES*", v.XRESStar, r.XRESStar},
+			{"KAUSF", v.KAUSF, r.KAUSF},
+		} {
+			if !bytes.Equal(f.got, f.want) {
+				t.Errorf("%s of vector #%d = %x, want %x", f.name, i, f.got, f.want)
+			}
+		}
+
+		if i > 0 && bytes.Equal(v.RAND, vectors[i-1].RAND) {
+			t.Errorf("RAND of vector #%d is replayed: %x", i, v.RAND)
+		}
+	}
+
+	if _, err := GenerateVectors(k, opc, 0x8000, 1, -1, testSNN); err == nil {
+		t.Error("GenerateVectors() with n = -1 = nil, want an error")
+	}
+}
@@ -0,0 +1,57 @@
+package aka
+
+import "fmt"
+
+// ValidateIMSI checks that imsi is one that ParseIMSI accepts, i.e. 14 or 15 decimal
+// digits (TS 23.003 2.2) of the 3-digit MCC followed by MNC and MSIN, so that a typo
+// doesn't silently end up in a wrong SNN or KAMF.
+func ValidateIMSI(imsi string) error {
+	_, err := ParseIMSI(imsi)
+	return err
+}
+
+// mccsWith3DigitMNC is the set of MCCs whose MNCs are 3 digits long (ITU-T E.212),
+// mainly the ones in North America and the Caribbean. MNCs of the other MCCs are
+// taken as 2 digits long.
+//
+// It's not exhaustive: some countries such as India (405) have both 2-digit and
+// 3-digit MNCs under the same MCC, which can't be told apart from the IMSI alone.
+// Those are listed here by the length of the MNCs mostly in use.
+var mccsWith3DigitMNC = map[string]bool{
+	"302": true, "310": true, "311": true, "312": true, "313": true, "314": true,
+	"315": true, "316": true, "330": true, "334": true, "338": true, "342": true,
+	"344": true, "346": true, "348": true, "352": true, "354": true, "356": true,
+	"358": true, "360": true, "365": true, "376": true, "405": true, "708": true,
+	"722": true, "732": true, "750": true,
+}
+
+// IMSI is an IMSI split into MCC, MNC and MSIN (TS 23.003 2.2).
+type IMSI struct {
+	MCC  string
+	MNC  string
+	MSIN string
+}
+
+// ParseIMSI splits a 14 or 15-digit IMSI into MCC, MNC and MSIN.
+// MNC is 3 digits long if MCC is one of the ones using 3-digit MNCs, 2 digits otherwise.
+func ParseIMSI(s string) (IMSI, error) {
+	if l := len(s); l < 14 || l > 15 {
+		return IMSI{}, fmt.Errorf("length of IMSI should be 14 or 15, got: %d", l)
+	}
+	for i, c := range s {
+		if c < '0' || c > '9' {
+			return IMSI{}, fmt.Errorf("IMSI should consist of digits, got %q at %d", c, i)
+		}
+	}
+
+	mncLen := 2
+	if mccsWith3DigitMNC[s[:3]] {
+		mncLen = 3
+	}
+	return IMSI{MCC: s[:3], MNC: s[3 : 3+mncLen], MSIN: s[3+mncLen:]}, nil
+}
+
+// String returns the IMSI as the digits MCC+MNC+MSIN.
+func (i IMSI) String() string {
+	return i.MCC + i.MNC + i.MSIN
+}
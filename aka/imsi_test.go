@@ -0,0 +1,34 @@
+package aka
+
+import "testing"
+
+func TestParseIMSI(t *testing.T) {
+	tests := []struct {
+		imsi    string
+		want    IMSI
+		wantErr bool
+	}{
+		{imsi: "001010123456789", want: IMSI{MCC: "001", MNC: "01", MSIN: "0123456789"}},
+		{imsi: "00101012345678", want: IMSI{MCC: "001", MNC: "01", MSIN: "012345678"}},
+		{imsi: "310260123456789", want: IMSI{MCC: "310", MNC: "260", MSIN: "123456789"}},
+		{imsi: "405854123456789", want: IMSI{MCC: "405", MNC: "854", MSIN: "123456789"}},
+		{imsi: "0010101234567", wantErr: true},
+		{imsi: "0010101234567890", wantErr: true},
+		{imsi: "00101012345678a", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIMSI(tt.imsi)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIMSI(%q): err = %v, wantErr %v", tt.imsi, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIMSI(%q) = %+v, want %+v", tt.imsi, got, tt.want)
+		}
+
+		// ValidateIMSI accepts exactly the IMSIs ParseIMSI does
+		if verr := ValidateIMSI(tt.imsi); (verr != nil) != (err != nil) {
+			t.Errorf("ValidateIMSI(%q) = %v, but ParseIMSI() returned %v", tt.imsi, verr, err)
+		}
+	}
+}
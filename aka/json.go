@@ -0,0 +1,67 @@
+package aka
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// jsonSchemaVersion is the version of the JSON representation of Aka.
+// It should be incremented on any incompatible change.
+const jsonSchemaVersion = 1
+
+type akaJSON struct {
+	V         int                `json:"v"`
+	SNN       string             `json:"snn"`
+	SUPI      string             `json:"supi"`
+	KAUSF     milenage.HexBytes  `json:"kausf"`
+	KSEAF     milenage.HexBytes  `json:"kseaf"`
+	KAMF      milenage.HexBytes  `json:"kamf"`
+	HXRESStar milenage.HexBytes  `json:"hxresStar"`
+	Milenage  *milenage.Milenage `json:"milenage"`
+}
+
+// MarshalJSON encodes the keys computed in Aka as hex strings along with SNN,
+// SUPI and the Milenage it's based on, in a versioned schema ("v": 1).
+//
+// It fails if Aka was created with AuthVectors other than *milenage.Milenage.
+func (a *Aka) MarshalJSON() ([]byte, error) {
+	mil, ok := a.av.(*milenage.Milenage)
+	if !ok {
+		return nil, fmt.Errorf("JSON is only supported with *milenage.Milenage, got: %T", a.av)
+	}
+	return json.Marshal(&akaJSON{
+		V:         jsonSchemaVersion,
+		SNN:       string(a.SNN),
+		SUPI:      string(a.SUPI),
+		KAUSF:     a.KAUSF,
+		KSEAF:     a.KSEAF,
+		KAMF:      a.KAMF,
+		HXRESStar: a.HXRESStar,
+		Milenage:  mil,
+	})
+}
+
+// UnmarshalJSON decodes Aka encoded by MarshalJSON.
+// It fails if the schema version is not the one supported.
+func (a *Aka) UnmarshalJSON(b []byte) error {
+	v := akaJSON{Milenage: &milenage.Milenage{}}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.V != jsonSchemaVersion {
+		return fmt.Errorf("unsupported schema version: %d", v.V)
+	}
+
+	*a = Aka{
+		av:        v.Milenage,
+		SNN:       []byte(v.SNN),
+		SUPI:      []byte(v.SUPI),
+		KAUSF:     v.KAUSF,
+		KSEAF:     v.KSEAF,
+		KAMF:      v.KAMF,
+		HXRESStar: v.HXRESStar,
+	}
+	return nil
+}
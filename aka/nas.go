@@ -0,0 +1,65 @@
+package aka
+
+import (
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// Algorithm type distinguishers used in the derivation of the keys for the NAS, RRC and
+// UP algorithms (Table A.8-1, TS 33.501).
+const (
+	AlgTypeNASEnc byte = 0x01
+	AlgTypeNASInt byte = 0x02
+	AlgTypeRRCEnc byte = 0x03
+	AlgTypeRRCInt byte = 0x04
+	AlgTypeUPEnc  byte = 0x05
+	AlgTypeUPInt  byte = 0x06
+)
+
+// NASSecurityContext is the 5G NAS security context held by the AMF (and the UE)
+// after a successful authentication (3.1, TS 33.501).
+//
+// KAMF, NgKSI and ABBA are populated by Aka.NASSecurityContext, and the rest are left
+// for the NAS layer to fill in after the NAS Security Mode Command procedure.
+type NASSecurityContext struct {
+	KAMF  []byte
+	NgKSI uint8
+	ABBA  uint16
+
+	// CipheringAlgorithm and IntegrityAlgorithm are the selected NAS algorithm
+	// identifiers, e.g. 2 for 128-NEA2 and 128-NIA2.
+	CipheringAlgorithm uint8
+	IntegrityAlgorithm uint8
+
+	// ULCount and DLCount are the NAS COUNTs for the uplink and downlink.
+	ULCount uint32
+	DLCount uint32
+}
+
+// NASSecurityContext returns a new NASSecurityContext with KAMF, NgKSI and ABBA in a.
+// KAMF is copied so the context stays valid after a is reused.
+func (a *Aka) NASSecurityContext() *NASSecurityContext {
+	return &NASSecurityContext{
+		KAMF:  append([]byte{}, a.KAMF...),
+		NgKSI: a.NgKSI,
+		ABBA:  a.ABBA,
+	}
+}
+
+// ComputeKNAS computes the key for the algorithm identified by algType and algID
+// (e.g. KNASenc with AlgTypeNASEnc and 2 for 128-NEA2) as described in A.8, TS 33.501.
+//
+// key is KAMF for the NAS keys and KgNB for the RRC and UP keys. The result is the
+// 128 least significant bits of the KDF output.
+func ComputeKNAS(key []byte, algType byte, algID byte) ([]byte, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%w: length of key should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(key))
+	}
+	if algType < AlgTypeNASEnc || algType > AlgTypeUPInt {
+		return nil, fmt.Errorf("unknown algorithm type distinguisher: %#02x", algType)
+	}
+
+	out := milenage.KDF(key, 0x69, []byte{algType}, []byte{algID})
+	return out[16:], nil
+}
@@ -0,0 +1,67 @@
+package aka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// ngKSINoKey is the value of ngKSI meaning that no key is available (9.11.3.32, TS 24.501).
+const ngKSINoKey = 0x07
+
+// SetNgKSI sets NgKSI, the key set identifier associated with KAMF (6.1.3.2, TS 33.501).
+//
+// tsc is the type of security context flag, which is true for a mapped security context
+// and false for a native one, and value is the 3-bit identifier assigned by the network.
+func (a *Aka) SetNgKSI(tsc bool, value uint8) error {
+	if value >= ngKSINoKey {
+		return fmt.Errorf("ngKSI should be between 0 and %d, got: %d", ngKSINoKey-1, value)
+	}
+
+	a.NgKSI = value
+	if tsc {
+		a.NgKSI |= 0x08
+	}
+	return nil
+}
+
+// NgKSIByte returns NgKSI in the value part of the NAS key set identifier IE
+// (9.11.3.32, TS 24.501), i.e. TSC in bit 4 and the identifier in bits 3 to 1.
+func (a *Aka) NgKSIByte() byte {
+	return a.NgKSI & 0x0f
+}
+
+// SEAFChallenge is the 5G AKA challenge sent by the SEAF to the UE in the NAS
+// Authentication Request (6.1.3.2, TS 33.501).
+type SEAFChallenge struct {
+	RAND []byte
+	AUTN []byte
+	// NgKSI is in the form returned by NgKSIByte.
+	NgKSI byte
+	// ABBA is the ABBA parameter in 2 bytes, as used in the derivation of KAMF.
+	ABBA []byte
+}
+
+// SEAFChallenge returns the challenge to be sent to the UE with RAND of a and autn
+// received from the AUSF, along with ngKSI and ABBA that KAMF is derived with.
+func (a *Aka) SEAFChallenge(autn []byte) (SEAFChallenge, error) {
+	if a.av == nil {
+		return SEAFChallenge{}, ErrNoAuthVectors
+	}
+	rand := a.av.GetRAND()
+	if len(rand) != 16 {
+		return SEAFChallenge{}, fmt.Errorf("%w: length of RAND should be %d, got: %d", milenage.ErrInvalidRANDLength, 16, len(rand))
+	}
+	if len(autn) != 16 {
+		return SEAFChallenge{}, fmt.Errorf("length of AUTN should be %d, got: %d", 16, len(autn))
+	}
+
+	return SEAFChallenge{
+		RAND:  bytes.Clone(rand),
+		AUTN:  bytes.Clone(autn),
+		NgKSI: a.NgKSIByte(),
+		ABBA:  binary.BigEndian.AppendUint16(nil, a.ABBA),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package aka
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// Access type distinguishers used in the derivation of KgNB (A.9, TS 33.501).
+const (
+	AccessType3GPP    byte = 0x01
+	AccessTypeNon3GPP byte = 0x02
+)
+
+// ComputeKgNB computes KgNB (or KN3IWF for non-3GPP access) from KAMF, the uplink
+// NAS COUNT and the access type distinguisher as described in A.9, TS 33.501.
+func ComputeKgNB(kamf []byte, ulNasCount uint32, accessType byte) ([]byte, error) {
+	if len(kamf) != 32 {
+		return nil, fmt.Errorf("%w: length of KAMF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kamf))
+	}
+
+	count := binary.BigEndian.AppendUint32(nil, ulNasCount)
+	return milenage.KDF(kamf, 0x6e, count, []byte{accessType}), nil
+}
+
+// ComputeNH computes NH (Next Hop) from KAMF and SYNC-input, i.e. the initial KgNB or
+// the previous NH, for the vertical key derivation as described in A.10, TS 33.501.
+func ComputeNH(kamf, syncInput []byte) ([]byte, error) {
+	if len(kamf) != 32 {
+		return nil, fmt.Errorf("%w: length of KAMF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kamf))
+	}
+	if len(syncInput) != 32 {
+		return nil, fmt.Errorf("length of SYNC-input should be %d, got: %d", 32, len(syncInput))
+	}
+
+	return milenage.KDF(kamf, 0x6f, syncInput), nil
+}
+
+// ComputeKgNBStar computes KgNB* from KgNB (or NH) and the PCI and ARFCN-DL of the target
+// cell for the horizontal (or vertical) key derivation on handover as described in
+// A.11, TS 33.501.
+func ComputeKgNBStar(kgnb []byte, pci uint16, arfcn uint32) ([]byte, error) {
+	if len(kgnb) != 32 {
+		return nil, fmt.Errorf("%w: length of KgNB should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kgnb))
+	}
+	if arfcn > 0xffffff {
+		return nil, fmt.Errorf("ARFCN-DL should fit in 3 bytes, got: %d", arfcn)
+	}
+
+	p0 := binary.BigEndian.AppendUint16(nil, pci)
+	p1 := binary.BigEndian.AppendUint32(nil, arfcn)[1:]
+	return milenage.KDF(kgnb, 0x70, p0, p1), nil
+}
+
+// Direction values used in the derivation of KAMF' (A.13, TS 33.501).
+const (
+	KAMFPrimeIdleMobility byte = 0x00
+	KAMFPrimeHandover     byte = 0x01
+)
+
+// ComputeKAMFPrime computes KAMF' from KAMF in a for the horizontal key derivation
+// on the change of AMF as described in A.13, TS 33.501. direction is
+// KAMFPrimeIdleMobility with the uplink NAS COUNT of the Registration Request, or
+// KAMFPrimeHandover with the downlink NAS COUNT.
+//
+// a.KAMF is left as is; the new AMF uses the result as its KAMF.
+func (a *Aka) ComputeKAMFPrime(direction byte, nasCount uint32) ([]byte, error) {
+	if len(a.KAMF) != 32 {
+		return nil, fmt.Errorf("%w: length of KAMF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(a.KAMF))
+	}
+	if direction != KAMFPrimeIdleMobility && direction != KAMFPrimeHandover {
+		return nil, fmt.Errorf("direction should be %#02x or %#02x, got: %#02x", KAMFPrimeIdleMobility, KAMFPrimeHandover, direction)
+	}
+
+	count := binary.BigEndian.AppendUint32(nil, nasCount)
+	a.traceValue("S(KAMF')", milenage.KDFInput(0x72, []byte{direction}, count))
+	return milenage.KDF(a.KAMF, 0x72, []byte{direction}, count), nil
+}
@@ -0,0 +1,28 @@
+package aka
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"testing"
+
+	"5G_AKA/milenage"
+)
+
+func TestComputeKAMFPrimeIgnoresHash(t *testing.T) {
+	kamf := mustHex(t, "c0d31ff6197fc31267b4a0a38790347ce5a74268114d53638c3db3d0be19a111")
+
+	a := New(nil, "5G:mnc001.mcc001.3gppnetwork.org", "001010123456789")
+	a.KAMF = kamf
+	a.Hash = sha512.New
+	a.TruncateKeysTo = 16
+
+	got, err := a.ComputeKAMFPrime(KAMFPrimeHandover, 1)
+	if err != nil {
+		t.Fatalf("ComputeKAMFPrime() failed: %v", err)
+	}
+	want := milenage.KDF(kamf, 0x72, []byte{KAMFPrimeHandover}, binary.BigEndian.AppendUint32(nil, 1))
+	if !bytes.Equal(got, want) {
+		t.Errorf("KAMF' = %x, want %x with HMAC-SHA-256 and not truncated", got, want)
+	}
+}
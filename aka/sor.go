@@ -0,0 +1,62 @@
+package aka
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// acknowledgement is the value of P0 in the derivations of SoR-MAC-IUE and UPU-MAC-IUE.
+const acknowledgement byte = 0x01
+
+// ComputeSoRMACIausf computes SoR-MAC-IAUSF from KAUSF, the SoR header, CounterSoR and
+// the steering list as described in A.17, TS 33.501, for the UDM to protect the Steering
+// of Roaming information. steeringList is omitted from the input if empty.
+//
+// The result is the 128 least significant bits of the KDF output.
+func ComputeSoRMACIausf(kausf, sorHeader []byte, counterSoR uint16, steeringList []byte) ([]byte, error) {
+	if len(kausf) != 32 {
+		return nil, fmt.Errorf("%w: length of KAUSF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kausf))
+	}
+
+	params := [][]byte{sorHeader, binary.BigEndian.AppendUint16(nil, counterSoR)}
+	if len(steeringList) > 0 {
+		params = append(params, steeringList)
+	}
+	return milenage.KDF(kausf, 0x77, params...)[16:], nil
+}
+
+// ComputeSoRMACIue computes SoR-MAC-IUE from KAUSF and CounterSoR as described
+// in A.18, TS 33.501, for the UE to acknowledge the Steering of Roaming information.
+func ComputeSoRMACIue(kausf []byte, counterSoR uint16) ([]byte, error) {
+	if len(kausf) != 32 {
+		return nil, fmt.Errorf("%w: length of KAUSF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kausf))
+	}
+
+	counter := binary.BigEndian.AppendUint16(nil, counterSoR)
+	return milenage.KDF(kausf, 0x78, []byte{acknowledgement}, counter)[16:], nil
+}
+
+// ComputeUPUMACIausf computes UPU-MAC-IAUSF from KAUSF, the UE Parameters Update Data
+// and CounterUPU as described in A.19, TS 33.501, for the UDM to protect the UE
+// Parameters Update.
+func ComputeUPUMACIausf(kausf, upuData []byte, counterUPU uint16) ([]byte, error) {
+	if len(kausf) != 32 {
+		return nil, fmt.Errorf("%w: length of KAUSF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kausf))
+	}
+
+	counter := binary.BigEndian.AppendUint16(nil, counterUPU)
+	return milenage.KDF(kausf, 0x7b, upuData, counter)[16:], nil
+}
+
+// ComputeUPUMACIue computes UPU-MAC-IUE from KAUSF and CounterUPU as described
+// in A.20, TS 33.501, for the UE to acknowledge the UE Parameters Update.
+func ComputeUPUMACIue(kausf []byte, counterUPU uint16) ([]byte, error) {
+	if len(kausf) != 32 {
+		return nil, fmt.Errorf("%w: length of KAUSF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kausf))
+	}
+
+	counter := binary.BigEndian.AppendUint16(nil, counterUPU)
+	return milenage.KDF(kausf, 0x7c, []byte{acknowledgement}, counter)[16:], nil
+}
@@ -0,0 +1,14 @@
+package aka
+
+// AuthVectors is the output of the authentication functions f1-f5 (or their equivalents
+// in another algorithm set such as TUAK) that the 5G key hierarchy is derived from.
+//
+// *milenage.Milenage implements it. A test double can also drive Aka with fixed values.
+type AuthVectors interface {
+	GetCK() []byte
+	GetIK() []byte
+	GetAK() []byte
+	GetSQN() []byte
+	GetRAND() []byte
+	GetRESStar() []byte
+}
@@ -0,0 +1,58 @@
+package aka
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"fmt"
+
+	"5G_AKA/milenage"
+)
+
+// VerifyKSEAF reports whether kseaf is the one derived from kausf under the serving
+// network name snn, so that the integrity of the key hierarchy can be checked from
+// logged values without the subscriber key.
+func VerifyKSEAF(kausf []byte, snn string, kseaf []byte) (bool, error) {
+	if len(kausf) != 32 {
+		return false, fmt.Errorf("%w: length of KAUSF should be %d, got: %d", milenage.ErrInvalidKeyLength, 32, len(kausf))
+	}
+	if len(kseaf) != 32 {
+		return false, fmt.Errorf("length of KSEAF should be %d, got: %d", 32, len(kseaf))
+	}
+
+	return hmac.Equal(milenage.KDF(kausf, 0x6c, []byte(snn)), kseaf), nil
+}
+
+// VerifyRESStar reports whether RES* received from the UE matches XRES*, as the AUSF
+// does in 6.1.3.2, TS 33.501. The comparison is constant-time, and false is returned
+// unless both are 16 bytes long.
+func VerifyRESStar(xresStar, resStar []byte) bool {
+	if len(xresStar) != 16 || len(resStar) != 16 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(xresStar, resStar) == 1
+}
+
+// VerifyHXRESStar computes HRES* from RAND and RES* received from the UE, and reports
+// whether it matches HXRES* computed by ComputeHXRESStar, as the SEAF does before
+// forwarding RES* to the AUSF (6.1.3.2, TS 33.501). The comparison is constant-time.
+func (a *Aka) VerifyHXRESStar(resStar []byte) (bool, error) {
+	if err := ValidateRESStar(resStar); err != nil {
+		return false, err
+	}
+	if len(a.HXRESStar) != 16 {
+		return false, fmt.Errorf("HXRES* should be computed beforehand: length of HXRES* should be %d, got: %d", 16, len(a.HXRESStar))
+	}
+	if a.av == nil {
+		return false, ErrNoAuthVectors
+	}
+	return subtle.ConstantTimeCompare(a.hresStar(resStar), a.HXRESStar) == 1, nil
+}
+
+// ValidateRESStar returns an error if resStar is not 16 bytes long (A.4, TS 33.501),
+// so that a malformed RES* is rejected instead of being hashed into a wrong HRES*.
+func ValidateRESStar(resStar []byte) error {
+	if len(resStar) != 16 {
+		return fmt.Errorf("length of RES* should be %d, got: %d", 16, len(resStar))
+	}
+	return nil
+}
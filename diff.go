@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"5G_AKA/aka"
+)
+
+// runDiff compares the values computed in r with the expected ones read from exp, and
+// prints PASS or FAIL for each of them to w. It returns true if all of them matched.
+//
+// Each line in exp is "NAME = hex" with NAME as printed by the CLI (e.g. "MAC-A" or
+// "KAUSF"), so a saved output can be used as is. Other lines are ignored.
+func runDiff(exp io.Reader, w io.Writer, r *aka.FlowResult) (bool, error) {
+	computed := map[string][]byte{
+		"OPc":       r.OPc,
+		"MAC-A":     r.MACA,
+		"CK":        r.CK,
+		"IK":        r.IK,
+		"AK":        r.AK,
+		"xRES":      r.XRES,
+		"xRESStar":  r.XRESStar,
+		"AUTN":      r.AUTN,
+		"KAUSF":     r.KAUSF,
+		"HXRESStar": r.HXRESStar,
+		"KSEAF":     r.KSEAF,
+		"KAMF":      r.KAMF,
+	}
+
+	var (
+		ok    = true
+		found int
+		sc    = bufio.NewScanner(exp)
+	)
+	for line := 1; sc.Scan(); line++ {
+		name, value, cut := strings.Cut(sc.Text(), "=")
+		if !cut {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		got, known := computed[name]
+		if !known {
+			continue
+		}
+
+		want, err := hex.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return false, fmt.Errorf("invalid %s at line %d: %w", name, line, err)
+		}
+		found++
+
+		if bytes.Equal(want, got) {
+			fmt.Fprintf(w, "PASS %-10s= %x\n", name, got)
+			continue
+		}
+		ok = false
+		fmt.Fprintf(w, "FAIL %-10s: expected %x, got %x\n", name, want, got)
+	}
+	if err := sc.Err(); err != nil {
+		return false, fmt.Errorf("failed to read expected values: %w", err)
+	}
+	if found == 0 {
+		return false, fmt.Errorf("no expected values found")
+	}
+	return ok, nil
+}
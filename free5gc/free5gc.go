@@ -0,0 +1,168 @@
+/*
+Package free5gc provides a loader for the test fixtures in the shape used by free5GC's
+UDM/AUSF, i.e. the authentication subscription data and the resulting 5G HE AV,
+so that they can be run through this module and compared.
+*/
+package free5gc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"5G_AKA/aka"
+	"5G_AKA/milenage"
+)
+
+// PermanentKey is the permanentKey object in AuthenticationSubscription.
+type PermanentKey struct {
+	PermanentKeyValue   string `json:"permanentKeyValue"`
+	EncryptionKey       int    `json:"encryptionKey"`
+	EncryptionAlgorithm int    `json:"encryptionAlgorithm"`
+}
+
+// OP is the op object in the milenage object of AuthenticationSubscription.
+type OP struct {
+	OPValue             string `json:"opValue"`
+	EncryptionKey       int    `json:"encryptionKey"`
+	EncryptionAlgorithm int    `json:"encryptionAlgorithm"`
+}
+
+// Milenage is the milenage object in AuthenticationSubscription.
+type Milenage struct {
+	OP *OP `json:"op,omitempty"`
+}
+
+// OPc is the opc object in AuthenticationSubscription.
+type OPc struct {
+	OPcValue            string `json:"opcValue"`
+	EncryptionKey       int    `json:"encryptionKey"`
+	EncryptionAlgorithm int    `json:"encryptionAlgorithm"`
+}
+
+// AuthenticationSubscription is the authentication subscription data stored in UDR.
+type AuthenticationSubscription struct {
+	AuthenticationMethod          string        `json:"authenticationMethod"`
+	PermanentKey                  *PermanentKey `json:"permanentKey"`
+	SequenceNumber                string        `json:"sequenceNumber"`
+	AuthenticationManagementField string        `json:"authenticationManagementField"`
+	Milenage                      *Milenage     `json:"milenage,omitempty"`
+	OPc                           *OPc          `json:"opc,omitempty"`
+}
+
+// Av5GHeAka is the 5G HE AV returned by UDM.
+type Av5GHeAka struct {
+	AvType   string `json:"avType"`
+	RAND     string `json:"rand"`
+	XRESStar string `json:"xresStar"`
+	AUTN     string `json:"autn"`
+	KAUSF    string `json:"kausf"`
+}
+
+// Fixture is a single test case: the subscription data, the serving network name
+// and the expected 5G HE AV.
+type Fixture struct {
+	AuthenticationSubscription AuthenticationSubscription `json:"authenticationSubscription"`
+	ServingNetworkName         string                     `json:"servingNetworkName"`
+	Av5GHeAka                  Av5GHeAka                  `json:"av5GHeAka"`
+}
+
+// Load reads a JSON array of Fixture from r.
+func Load(r io.Reader) ([]Fixture, error) {
+	var fixtures []Fixture
+	if err := json.NewDecoder(r).Decode(&fixtures); err != nil {
+		return nil, fmt.Errorf("failed to decode fixtures: %w", err)
+	}
+	return fixtures, nil
+}
+
+// Check runs the fixture through milenage and aka, and returns an error
+// describing the first field that doesn't match the expected 5G HE AV.
+//
+// KAUSF is checked only if it's present in the fixture.
+func (f *Fixture) Check() error {
+	sub := f.AuthenticationSubscription
+	if sub.PermanentKey == nil {
+		return fmt.Errorf("permanentKey is missing")
+	}
+
+	k, err := hex.DecodeString(sub.PermanentKey.PermanentKeyValue)
+	if err != nil {
+		return fmt.Errorf("invalid permanentKeyValue: %w", err)
+	}
+	sqn, err := strconv.ParseUint(sub.SequenceNumber, 16, 48)
+	if err != nil {
+		return fmt.Errorf("invalid sequenceNumber: %w", err)
+	}
+	amf, err := strconv.ParseUint(sub.AuthenticationManagementField, 16, 16)
+	if err != nil {
+		return fmt.Errorf("invalid authenticationManagementField: %w", err)
+	}
+	rand, err := hex.DecodeString(f.Av5GHeAka.RAND)
+	if err != nil {
+		return fmt.Errorf("invalid rand: %w", err)
+	}
+
+	var m *milenage.Milenage
+	switch {
+	case sub.OPc != nil && sub.OPc.OPcValue != "":
+		opc, err := hex.DecodeString(sub.OPc.OPcValue)
+		if err != nil {
+			return fmt.Errorf("invalid opcValue: %w", err)
+		}
+		m = milenage.NewWithOPc(k, opc, rand, sqn, uint16(amf))
+	case sub.Milenage != nil && sub.Milenage.OP != nil:
+		op, err := hex.DecodeString(sub.Milenage.OP.OPValue)
+		if err != nil {
+			return fmt.Errorf("invalid opValue: %w", err)
+		}
+		m = milenage.New(k, op, rand, sqn, uint16(amf))
+	default:
+		return fmt.Errorf("neither opc nor milenage.op is present")
+	}
+
+	if _, err := m.F1(); err != nil {
+		return fmt.Errorf("F1() failed: %w", err)
+	}
+	if _, _, _, _, err := m.F2345(); err != nil {
+		return fmt.Errorf("F2345() failed: %w", err)
+	}
+	autn, err := m.GenerateAUTN()
+	if err != nil {
+		return fmt.Errorf("GenerateAUTN() failed: %w", err)
+	}
+	m.RESStar, err = m.ComputeRESStarSNN(f.ServingNetworkName)
+	if err != nil {
+		return fmt.Errorf("ComputeRESStar() failed: %w", err)
+	}
+
+	if err := compare("autn", f.Av5GHeAka.AUTN, autn); err != nil {
+		return err
+	}
+	if err := compare("xresStar", f.Av5GHeAka.XRESStar, m.RESStar); err != nil {
+		return err
+	}
+
+	if f.Av5GHeAka.KAUSF == "" {
+		return nil
+	}
+	kausf, err := aka.New(m, f.ServingNetworkName, "").ComputeKAUSF()
+	if err != nil {
+		return fmt.Errorf("ComputeKAUSF() failed: %w", err)
+	}
+	return compare("kausf", f.Av5GHeAka.KAUSF, kausf)
+}
+
+func compare(name, expected string, got []byte) error {
+	want, err := hex.DecodeString(expected)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("%s mismatch: expected %x, got %x", name, want, got)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package free5gc
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadFixtures(t *testing.T) []Fixture {
+	t.Helper()
+	f, err := os.Open("testdata/fixtures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fixtures, err := Load(f)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("Load() returned no fixtures")
+	}
+	return fixtures
+}
+
+func TestCheck(t *testing.T) {
+	for _, f := range loadFixtures(t) {
+		t.Run(f.Av5GHeAka.RAND, func(t *testing.T) {
+			if err := f.Check(); err != nil {
+				t.Errorf("Check() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckMismatch(t *testing.T) {
+	f := loadFixtures(t)[0]
+	f.Av5GHeAka.AUTN = "00" + f.Av5GHeAka.AUTN[2:]
+	err := f.Check()
+	if err == nil || !strings.Contains(err.Error(), "autn mismatch") {
+		t.Errorf("Check() with a modified AUTN: err = %v, want an autn mismatch", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// envSentinel is the flag value to take the value from the environment variable.
+const envSentinel = "env:"
+
+// hexFlag is a flag.Value holding bytes given in hex string.
+//
+// The "0x" prefix is accepted, and the digits can be in either case.
+// If sizes are given, the value must be exactly one of them in bytes.
+type hexFlag struct {
+	value []byte
+	sizes []int
+
+	// explicit is true if the value is given on the command line.
+	explicit bool
+	// fromEnv is true if envSentinel is given on the command line.
+	fromEnv bool
+}
+
+// newHexFlag creates a hexFlag with the default value given in hex string.
+func newHexFlag(def string, sizes ...int) *hexFlag {
+	f := &hexFlag{sizes: sizes}
+	if def == "" {
+		return f
+	}
+	if err := f.Set(def); err != nil {
+		panic(fmt.Sprintf("invalid default value %q: %v", def, err))
+	}
+	f.explicit = false
+	return f
+}
+
+func (f *hexFlag) String() string {
+	return hex.EncodeToString(f.value)
+}
+
+func (f *hexFlag) Set(s string) error {
+	if s == envSentinel {
+		f.fromEnv = true
+		f.explicit = false
+		return nil
+	}
+
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(f.sizes) != 0 && !slices.Contains(f.sizes, len(b)) {
+		return fmt.Errorf("length should be %v bytes, got: %d", f.sizes, len(b))
+	}
+
+	f.value = b
+	f.explicit = true
+	return nil
+}
+
+// loadEnv sets the value from the environment variable name unless the value
+// is given explicitly on the command line, i.e. the precedence is flag > env > default.
+//
+// If envSentinel is given on the command line, the environment variable must be set.
+func (f *hexFlag) loadEnv(name string) error {
+	if f.explicit {
+		return nil
+	}
+
+	v := os.Getenv(name)
+	if v == "" {
+		if f.fromEnv {
+			return fmt.Errorf("%s is not set", name)
+		}
+		return nil
+	}
+
+	if err := f.Set(v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHexFlagLoadEnv(t *testing.T) {
+	const (
+		name = "AKA_TEST_K"
+		def  = "00112233445566778899aabbccddeeff"
+		env  = "ffeeddccbbaa99887766554433221100"
+		flag = "0x000102030405060708090a0b0c0d0e0f"
+	)
+
+	tests := []struct {
+		name    string
+		arg     string // value on the command line, not given if empty
+		env     string // value of the environment variable, unset if empty
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: def},
+		{name: "env over default", env: env, want: env},
+		{name: "flag over env", arg: flag, env: env, want: flag[2:]},
+		{name: "flag without env", arg: flag, want: flag[2:]},
+		{name: "env: with the variable", arg: envSentinel, env: env, want: env},
+		{name: "env: without the variable", arg: envSentinel, wantErr: true},
+		{name: "invalid env", env: "xyz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(name, tt.env)
+
+			f := newHexFlag(def, 16)
+			if tt.arg != "" {
+				if err := f.Set(tt.arg); err != nil {
+					t.Fatalf("Set(%q) failed: %v", tt.arg, err)
+				}
+			}
+
+			err := f.loadEnv(name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("loadEnv() = nil, want an error; value: %x", f.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadEnv() failed: %v", err)
+			}
+			if want, _ := hex.DecodeString(tt.want); !bytes.Equal(f.value, want) {
+				t.Errorf("value = %x, want %s", f.value, tt.want)
+			}
+		})
+	}
+}
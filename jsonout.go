@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"5G_AKA/aka"
+)
+
+// writeJSON writes the inputs in params and all the values in r to w as a single
+// JSON object with the values in hex, in the same form as the fixtures (see aka.Fixture).
+//
+// op is nil if OPc was given instead of OP.
+func writeJSON(w io.Writer, params aka.FlowParams, op []byte, r *aka.FlowResult) error {
+	err := json.NewEncoder(w).Encode(aka.Fixture{
+		IMSI:   params.IMSI,
+		MCC:    params.MCC,
+		MNC:    params.MNC,
+		K:      params.K,
+		OP:     op,
+		SQN:    params.SQN,
+		AMF:    params.AMF,
+		RAND:   params.RAND,
+		Result: r,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
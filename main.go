@@ -2,10 +2,12 @@ package main
 
 import (
 	// crand "crypto/rand"
-	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"runtime"
 	"strconv"
 
 	"5G_AKA/aka"
@@ -13,30 +15,87 @@ import (
 )
 
 func main() {
+	// "diff" subcommand compares the computed values with the expected ones
+	// in the file given as the argument after the flags.
+	var diffMode bool
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffMode = true
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	var (
-		imsis = flag.String("imsi", "001010123456789", "IMSI in string") // supported length: MCC 3 MNC 2
-		ks    = flag.String("k", "00112233445566778899aabbccddeeff", "K in hex string")
-		ops   = flag.String("op", "00112233445566778899aabbccddeeff", "OP in hex string")
+		imsis = flag.String("imsi", "001010123456789", "IMSI in string")
+		k     = newHexFlag("00112233445566778899aabbccddeeff", 16, 32)
+		op    = newHexFlag("00112233445566778899aabbccddeeff", 16)
+		opcs  = newHexFlag("", 16)
 		sqns  = flag.String("sqn", "000000000001", "SQN in hex string")
 		amfs  = flag.String("amf", "8000", "AMF in hex string")
-		rands = flag.String("rand", "00112233445566778899aabbccddeeff", "RAND in hex string")
+		rand  = newHexFlag("00112233445566778899aabbccddeeff", 16)
+		trace bool
+
+		batch   = flag.String("batch", "", "compute vectors for each row in the CSV file (\"-\" for stdin) and print them in CSV")
+		workers = flag.Int("workers", runtime.NumCPU(), "number of workers used with -batch")
+
+		randStdin = flag.Bool("rand-stdin", false, "read RAND in hex per line from stdin and print a vector per line")
+		sqnStep   = flag.Uint64("sqn-step", 0, "amount to increment SQN by per line with -rand-stdin")
+
+		format = flag.String("format", "text", "output format: \"text\" or \"json\"")
+		mode   = flag.String("mode", "5g", "\"5g\" to run the whole 5G AKA flow, or \"eps\" to compute an EPS authentication vector only")
 	)
+	// K, OP and OPc can be given in the environment variables instead, to keep
+	// them out of the process listings. The flag takes precedence over the
+	// environment variable, which takes precedence over the default value.
+	flag.Var(k, "k", "K in hex string (16 bytes, or 32 for AES-256), or \"env:\" to read from AKA_K")
+	flag.Var(op, "op", "OP in hex string, or \"env:\" to read from AKA_OP")
+	flag.Var(opcs, "opc", "OPc in hex string (used instead of OP if given), or \"env:\" to read from AKA_OPC")
+	flag.Var(rand, "rand", "RAND in hex string")
+	flag.BoolVar(&trace, "trace", false, "print all the intermediate values")
+	flag.BoolVar(&trace, "verbose", false, "alias of -trace")
 	flag.Parse()
 
-	// provided by UDM
-	k, err := hex.DecodeString(*ks)
-	if err != nil {
-		log.Fatalf("Invalid K \"%s\": %+v", *ks, err)
+	if *format != "text" && *format != "json" {
+		log.Fatalf("Invalid format \"%s\": should be \"text\" or \"json\"", *format)
+	}
+	if *mode != "5g" && *mode != "eps" {
+		log.Fatalf("Invalid mode \"%s\": should be \"5g\" or \"eps\"", *mode)
+	}
+	if *mode == "eps" && (diffMode || *randStdin || *batch != "") {
+		log.Fatalf("-mode eps can't be used with diff, -rand-stdin or -batch")
+	}
+	if *format == "json" && (diffMode || *batch != "") {
+		log.Fatalf("-format json can't be used with diff or -batch")
 	}
 
-	// provided by UDM
-	op, err := hex.DecodeString(*ops)
-	if err != nil {
-		log.Fatalf("Invalid OP \"%s\": %+v", *ops, err)
+	if *batch != "" {
+		in := os.Stdin
+		if *batch != "-" {
+			f, err := os.Open(*batch)
+			if err != nil {
+				log.Fatalf("Failed to open CSV: %+v", err)
+			}
+			defer f.Close()
+			in = f
+		}
+		if err := milenage.BatchComputeCSV(in, os.Stdout, *workers); err != nil {
+			log.Fatalf("BatchComputeCSV() failed: %+v", err)
+		}
+		return
 	}
-	opc, err := milenage.ComputeOPc(k, op)
-	if err != nil {
-		log.Fatalf("Failed to compute OPc: %+v", err)
+
+	for name, f := range map[string]*hexFlag{"AKA_K": k, "AKA_OP": op, "AKA_OPC": opcs} {
+		if err := f.loadEnv(name); err != nil {
+			log.Fatalf("Failed to read environment: %+v", err)
+		}
+	}
+
+	// K and OP (or OPc) are provided by UDM
+	opc := opcs.value
+	if opc == nil {
+		var err error
+		opc, err = milenage.ComputeOPc(k.value, op.value)
+		if err != nil {
+			log.Fatalf("Failed to compute OPc: %+v", err)
+		}
 	}
 
 	// provided by UDM
@@ -51,87 +110,126 @@ func main() {
 		log.Fatalf("Invalid AMF \"%s\": %+v", *amfs, err)
 	}
 
-	// RAND from CLI
-	rand, err := hex.DecodeString(*rands)
-	if err != nil {
-		log.Fatalf("Invalid RAND \"%s\": %+v", *rands, err)
-	}
 	// RAND from random
-	// rand = make([]byte, 16)
-	// _, err = crand.Read(rand)
+	// rand.value = make([]byte, 16)
+	// _, err = crand.Read(rand.value)
 	// if err != nil {
 	// 	log.Fatalf("Failed to generate random RAND: %+v", err)
 	// }
 
-	mcc := (*imsis)[0:3]
-	mnc := (*imsis)[3:5]
-
-	fmt.Printf("IMSI     = %s %s %s\n", mcc, mnc, (*imsis)[5:])
-	fmt.Printf("K        = %x\n", k)
-	fmt.Printf("OPc      = %x\n", opc)
-	fmt.Printf("SQN      = %x\n", sqn)
-	fmt.Printf("AMF      = %x\n", amf)
-	fmt.Printf("RAND     = %x\n", rand)
-	fmt.Println()
-
-	fmt.Printf("-------- MILENAGE ops @ UDM --------\n")
-	m := milenage.NewWithOPc(k, opc, rand, sqn, amf)
-
-	maca, err := m.F1()
+	imsi, err := aka.ParseIMSI(*imsis)
 	if err != nil {
-		log.Fatalf("F1() failed: %+v", err)
+		log.Fatalf("Invalid IMSI \"%s\": %+v", *imsis, err)
 	}
-	fmt.Printf("MAC-A    = %x\n", maca)
 
-	xres, ck, ik, ak, err := m.F2345()
-	if err != nil {
-		log.Fatalf("F2345() failed: %+v", err)
+	params := aka.FlowParams{
+		IMSI: *imsis,
+		MCC:  imsi.MCC,
+		MNC:  imsi.MNC,
+		K:    k.value,
+		OPc:  opc,
+		SQN:  sqn,
+		AMF:  amf,
+		RAND: rand.value,
 	}
-	fmt.Printf("CK       = %x\n", ck)
-	fmt.Printf("IK       = %x\n", ik)
-	fmt.Printf("AK       = %x\n", ak)
-	fmt.Printf("xRES     = %x\n", xres)
 
-	m.RESStar, err = m.ComputeRESStar((*imsis)[0:3], (*imsis)[3:5])
-	xRESStar := m.RESStar
-	if err != nil {
-		log.Fatalf("Failed to compute RESStar: %+v", err)
+	// OP goes into the JSON output only if it's what OPc is computed from
+	var opValue []byte
+	if opcs.value == nil {
+		opValue = op.value
 	}
-	fmt.Printf("xRESStar = %x\n", xRESStar)
 
-	autn, err := m.GenerateAUTN()
-	if err != nil {
-		log.Fatalf("GenerateAUTN() failed: %+v", err)
+	if *randStdin {
+		if err := runRANDStream(os.Stdin, os.Stdout, params, opValue, *sqnStep, *format); err != nil {
+			log.Fatalf("Failed to process RAND from stdin: %+v", err)
+		}
+		return
 	}
-	fmt.Printf("AUTN     = %x\n", autn)
 
-	// Resync stuff
-	// macs, err := m.F1Star(m.SQN, m.AMF)
-	// if err != nil {
-	// 	log.Fatalf("F1Star() failed: %+v", err)
-	// }
-	// fmt.Printf("MAC-S    = %x\n", macs)
+	if diffMode {
+		if flag.NArg() != 1 {
+			log.Fatalf("Usage: %s diff [flags] <file of expected values>", os.Args[0])
+		}
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("Failed to open expected values: %+v", err)
+		}
+		defer f.Close()
 
-	// aks, err := m.F5Star()
-	// if err != nil {
-	// 	log.Fatalf("F5Star() failed: %+v", err)
-	// }
-	// fmt.Printf("AKS      = %x\n", aks)
+		r, err := aka.RunFlow(params)
+		if err != nil {
+			log.Fatalf("RunFlow() failed: %+v", err)
+		}
+		ok, err := runDiff(f, os.Stdout, r)
+		if err != nil {
+			log.Fatalf("Failed to compare: %+v", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
 
-	// auts, err := m.GenerateAUTS()
-	// if err != nil {
-	// 	log.Fatalf("GenerateAUTS() failed: %+v", err)
-	// }
-	// fmt.Printf("AUTS     = %x\n", auts)
+	if *mode == "eps" {
+		v, err := aka.ComputeEPSAuthVector(k.value, opc, rand.value, sqn, amf, imsi.MCC, imsi.MNC)
+		if err != nil {
+			log.Fatalf("ComputeEPSAuthVector() failed: %+v", err)
+		}
+		if *format == "json" {
+			if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+				log.Fatalf("Failed to write JSON: %+v", err)
+			}
+			return
+		}
 
-	snn := fmt.Sprintf("5G:mnc%s.mcc%s.3gppnetwork.org", mnc, mcc)
-	a := aka.New(*m, snn, *imsis)
+		printInputs(imsi, k.value, opc, sqn, amf, rand.value)
+		fmt.Printf("-------- EPS AKA ops @ HSS --------\n")
+		fmt.Printf("xRES     = %x\n", v.XRES)
+		fmt.Printf("AUTN     = %x\n", v.AUTN)
+		fmt.Printf("KASME    = %x\n", v.KASME)
+		return
+	}
 
-	kausf, err := a.ComputeKAUSF()
+	if *format == "json" {
+		// stdout is kept for the JSON object only
+		if trace {
+			params.Trace = func(name string, value []byte) {
+				fmt.Fprintf(os.Stderr, "  %-10s= %x\n", name, value)
+			}
+		}
+		r, err := aka.RunFlow(params)
+		if err != nil {
+			log.Fatalf("RunFlow() failed: %+v", err)
+		}
+		if err := writeJSON(os.Stdout, params, opValue, r); err != nil {
+			log.Fatalf("Failed to write JSON: %+v", err)
+		}
+		return
+	}
+
+	printInputs(imsi, k.value, opc, sqn, amf, rand.value)
+
+	// the traced values are printed under the section where they're computed
+	var traces traceBuffer
+	if trace {
+		params.Trace = traces.add
+	}
+
+	r, err := aka.RunFlow(params)
 	if err != nil {
-		log.Fatalf("ComputeKAUSF() failed: %+v", err)
+		log.Fatalf("RunFlow() failed: %+v", err)
 	}
-	fmt.Printf("KAUSF    = %x\n", kausf)
+
+	fmt.Printf("-------- MILENAGE ops @ UDM --------\n")
+	traces.printUntil(os.Stdout, "S(KAUSF)")
+	fmt.Printf("MAC-A    = %x\n", r.MACA)
+	fmt.Printf("CK       = %x\n", r.CK)
+	fmt.Printf("IK       = %x\n", r.IK)
+	fmt.Printf("AK       = %x\n", r.AK)
+	fmt.Printf("xRES     = %x\n", r.XRES)
+	fmt.Printf("xRESStar = %x\n", r.XRESStar)
+	fmt.Printf("AUTN     = %x\n", r.AUTN)
+	fmt.Printf("KAUSF    = %x\n", r.KAUSF)
 	fmt.Println()
 
 	////////////////////////////////////////
@@ -140,12 +238,8 @@ func main() {
 	fmt.Printf("******** UDM -> AUSF: RAND, xRESStar, AUTN, KAUSF ********\n")
 	fmt.Println()
 	fmt.Printf("-------- 5G AKA ops @ AUSF --------\n")
-
-	hxresstar, err := a.ComputeHXRESStar()
-	if err != nil {
-		log.Fatalf("ComputeHXRESStar() failed: %+v", err)
-	}
-	fmt.Printf("HXRESStar= %x\n", hxresstar)
+	traces.printUntil(os.Stdout, "S(HXRES*)")
+	fmt.Printf("HXRESStar= %x\n", r.HXRESStar)
 	fmt.Println()
 
 	////////////////////////////////////////
@@ -168,19 +262,24 @@ func main() {
 	fmt.Println()
 
 	fmt.Printf("-------- 5G AKA ops @ AUSF --------\n")
-	kseaf, err := a.ComputeKSEAF()
-	if err != nil {
-		log.Fatalf("ComputeKSEAF() failed: %+v", err)
-	}
-	fmt.Printf("KSEAF    = %x\n", kseaf)
+	traces.printUntil(os.Stdout, "S(KSEAF)")
+	fmt.Printf("KSEAF    = %x\n", r.KSEAF)
 	fmt.Println()
 	fmt.Printf("******** AUSF -> SEAF: SUPI, KSEAF ********\n")
 	fmt.Println()
 
 	fmt.Printf("-------- 5G AKA ops @ SEAF --------\n")
-	kamf, err := a.ComputeKAMF()
-	if err != nil {
-		log.Fatalf("ComputeKAMF() failed: %+v", err)
-	}
-	fmt.Printf("KAMF     = %x\n", kamf)
+	traces.printUntil(os.Stdout, "")
+	fmt.Printf("KAMF     = %x\n", r.KAMF)
+}
+
+// printInputs prints the inputs common to the 5G and EPS outputs.
+func printInputs(imsi aka.IMSI, k, opc []byte, sqn uint64, amf uint16, rand []byte) {
+	fmt.Printf("IMSI     = %s %s %s\n", imsi.MCC, imsi.MNC, imsi.MSIN)
+	fmt.Printf("K        = %x\n", k)
+	fmt.Printf("OPc      = %x\n", opc)
+	fmt.Printf("SQN      = %x\n", sqn)
+	fmt.Printf("AMF      = %x\n", amf)
+	fmt.Printf("RAND     = %x\n", rand)
+	fmt.Println()
 }
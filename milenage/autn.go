@@ -0,0 +1,68 @@
+package milenage
+
+import "fmt"
+
+// AUTN is the authentication token sent to the UE, i.e. SQN xor AK || AMF || MAC-A
+// (6.3.2, TS 33.102).
+type AUTN struct {
+	SQNxorAK [6]byte
+	AMF      [2]byte
+	MAC      [8]byte
+}
+
+// ParseAUTNBytes splits a 16-byte AUTN into its fields.
+func ParseAUTNBytes(b []byte) (AUTN, error) {
+	if len(b) != 16 {
+		return AUTN{}, fmt.Errorf("length of AUTN should be %d, got: %d", 16, len(b))
+	}
+
+	var a AUTN
+	copy(a.SQNxorAK[:], b[0:6])
+	copy(a.AMF[:], b[6:8])
+	copy(a.MAC[:], b[8:16])
+	return a, nil
+}
+
+// Bytes returns the 16-byte AUTN.
+func (a AUTN) Bytes() []byte {
+	b := make([]byte, 0, 16)
+	b = append(b, a.SQNxorAK[:]...)
+	b = append(b, a.AMF[:]...)
+	return append(b, a.MAC[:]...)
+}
+
+// String returns the fields of AUTN in hex, e.g. for comparison with a packet capture.
+func (a AUTN) String() string {
+	return fmt.Sprintf("SQN^AK=%x AMF=%x MAC=%x", a.SQNxorAK, a.AMF, a.MAC)
+}
+
+// AUTS is the re-synchronisation token sent back by the UE, i.e. SQN_MS xor AK* || MAC-S
+// (6.3.3, TS 33.102).
+type AUTS struct {
+	SQNxorAKS [6]byte
+	MACS      [8]byte
+}
+
+// ParseAUTSBytes splits a 14-byte AUTS into its fields.
+func ParseAUTSBytes(b []byte) (AUTS, error) {
+	if len(b) != 14 {
+		return AUTS{}, fmt.Errorf("length of AUTS should be %d, got: %d", 14, len(b))
+	}
+
+	var a AUTS
+	copy(a.SQNxorAKS[:], b[0:6])
+	copy(a.MACS[:], b[6:14])
+	return a, nil
+}
+
+// Bytes returns the 14-byte AUTS.
+func (a AUTS) Bytes() []byte {
+	b := make([]byte, 0, 14)
+	b = append(b, a.SQNxorAKS[:]...)
+	return append(b, a.MACS[:]...)
+}
+
+// String returns the fields of AUTS in hex, e.g. for comparison with a packet capture.
+func (a AUTS) String() string {
+	return fmt.Sprintf("SQN_MS^AK*=%x MAC-S=%x", a.SQNxorAKS, a.MACS)
+}
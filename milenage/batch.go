@@ -0,0 +1,179 @@
+package milenage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Input is a set of parameters to compute a single authentication vector.
+//
+// Either OP or OPc should be given. If both are given, OPc is used.
+type Input struct {
+	K    []byte
+	OP   []byte
+	OPc  []byte
+	RAND []byte
+	SQN  uint64
+	AMF  uint16
+}
+
+// Vector is a set of values computed with MILENAGE for a single Input.
+type Vector struct {
+	RAND []byte
+	SQN  []byte
+	AMF  []byte
+	MACA []byte
+	XRES []byte
+	CK   []byte
+	IK   []byte
+	AK   []byte
+	AUTN []byte
+}
+
+// BatchCompute computes vectors for all the inputs given, using the given
+// number of workers concurrently. The vectors are returned in the same order
+// as the inputs.
+//
+// Each input is computed with its own Milenage, so no state is shared between workers.
+//
+// The inputs are computed as given, so the same (SQN, RAND) pair may appear more than once.
+// Use BatchComputeChecked or call CheckNoReplays on the result to assert that it doesn't.
+func BatchCompute(inputs []Input, workers int) ([]Vector, error) {
+	return BatchComputeProgress(inputs, workers, nil)
+}
+
+// BatchComputeChecked is BatchCompute that also runs CheckNoReplays on the result,
+// and returns its error instead of the vectors if any (SQN, RAND) pair is repeated.
+func BatchComputeChecked(inputs []Input, workers int) ([]Vector, error) {
+	vectors, err := BatchCompute(inputs, workers)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckNoReplays(vectors); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// BatchComputeProgress is BatchCompute with a callback that is invoked as vectors
+// complete, with the number of vectors done so far and the total.
+//
+// The callback is never invoked concurrently, and is throttled to be invoked
+// at most around 100 times per batch. The last invocation always has done == total
+// unless the computation fails.
+func BatchComputeProgress(inputs []Input, workers int, onProgress func(done, total int)) ([]Vector, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	total := len(inputs)
+	step := total / 100
+	if step < 1 {
+		step = 1
+	}
+
+	var (
+		vectors = make([]Vector, total)
+		errs    = make([]error, total)
+		indices = make(chan int)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		done    int
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				vectors[i], errs[i] = computeVector(inputs[i])
+
+				if onProgress == nil {
+					continue
+				}
+				mu.Lock()
+				done++
+				if done%step == 0 || done == total {
+					onProgress(done, total)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range inputs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute vector for input #%d: %w", i, err)
+		}
+	}
+	return vectors, nil
+}
+
+// CheckNoReplays returns an error if any two of the vectors share the same (SQN, RAND)
+// pair, which must never happen as the UE would see it as a replayed challenge.
+// The error lists the indices of all the offending pairs.
+func CheckNoReplays(vectors []Vector) error {
+	var (
+		seen    = make(map[string]int, len(vectors))
+		replays []string
+	)
+	for i, v := range vectors {
+		key := string(v.SQN) + string(v.RAND)
+		if j, ok := seen[key]; ok {
+			replays = append(replays, fmt.Sprintf("#%d and #%d", j, i))
+			continue
+		}
+		seen[key] = i
+	}
+
+	if len(replays) > 0 {
+		return fmt.Errorf("vectors share the same SQN and RAND: %s", strings.Join(replays, ", "))
+	}
+	return nil
+}
+
+// ComputeVector computes a Vector from K, OPc, RAND, SQN and AMF without any shared state,
+// so it can be called from any number of goroutines with the same K and OPc.
+func ComputeVector(k, opc, rand []byte, sqn uint64, amf uint16) (Vector, error) {
+	return computeVector(Input{K: k, OPc: opc, RAND: rand, SQN: sqn, AMF: amf})
+}
+
+// computeVector computes a Vector from in with a dedicated Milenage.
+func computeVector(in Input) (Vector, error) {
+	var m *Milenage
+	if in.OPc != nil {
+		m = NewWithOPc(in.K, in.OPc, in.RAND, in.SQN, in.AMF)
+	} else {
+		m = New(in.K, in.OP, in.RAND, in.SQN, in.AMF)
+	}
+
+	if _, err := m.F1(); err != nil {
+		return Vector{}, fmt.Errorf("F1() failed: %w", err)
+	}
+	if _, _, _, _, err := m.F2345(); err != nil {
+		return Vector{}, fmt.Errorf("F2345() failed: %w", err)
+	}
+	autn, err := m.GenerateAUTN()
+	if err != nil {
+		return Vector{}, fmt.Errorf("GenerateAUTN() failed: %w", err)
+	}
+
+	return Vector{
+		RAND: m.RAND,
+		SQN:  m.SQN,
+		AMF:  m.AMF,
+		MACA: m.MACA,
+		XRES: m.RES,
+		CK:   m.CK,
+		IK:   m.IK,
+		AK:   m.AK,
+		AUTN: autn,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package milenage_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"5G_AKA/aka"
+	"5G_AKA/milenage"
+)
+
+// BenchmarkAuthenticateParallel runs the whole network side of 5G AKA, from f1 to KAMF,
+// on all the goroutines with a Milenage cloned for each of them.
+func BenchmarkAuthenticateParallel(b *testing.B) {
+	const (
+		snn  = "5G:mnc001.mcc001.3gppnetwork.org"
+		supi = "001010123456789"
+	)
+	k, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+	opc, _ := hex.DecodeString("62e75b8d6fa5bf46ec87a9276f9df54d")
+	rand, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+	template := milenage.NewWithOPc(k, opc, rand, 1, 0x8000)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		m := template.Clone()
+		for pb.Next() {
+			// a fresh RAND for each challenge, so that nothing cached is reused
+			m.RAND[0]++
+			if _, err := m.F1(); err != nil {
+				b.Fatal(err)
+			}
+			if _, _, _, _, err := m.F2345(); err != nil {
+				b.Fatal(err)
+			}
+			resStar, err := m.ComputeRESStarSNN(snn)
+			if err != nil {
+				b.Fatal(err)
+			}
+			m.RESStar = resStar
+			if _, err := m.GenerateAUTN(); err != nil {
+				b.Fatal(err)
+			}
+
+			a := aka.New(m, snn, supi)
+			if _, err := a.ComputeKAUSF(); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := a.ComputeHXRESStar(); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := a.ComputeKSEAF(); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := a.ComputeKAMF(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package milenage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// ExternalCrypto is the kernel function E_K of MILENAGE (i.e. AES-128 keyed with K)
+// provided from outside, so that K never has to leave e.g. an HSM.
+//
+// Encrypt is given a 16-byte block and should return the 16-byte encrypted block.
+type ExternalCrypto interface {
+	Encrypt(block []byte) []byte
+}
+
+// softwareCrypto is the ExternalCrypto that encrypts in software with the given K.
+type softwareCrypto struct {
+	block cipher.Block
+}
+
+// NewSoftwareCrypto returns the software implementation of ExternalCrypto with K,
+// which is what Milenage uses by default when Crypto is not set.
+func NewSoftwareCrypto(k []byte) (ExternalCrypto, error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return &softwareCrypto{block: block}, nil
+}
+
+// Encrypt encrypts a single block.
+func (c *softwareCrypto) Encrypt(block []byte) []byte {
+	out := make([]byte, len(block))
+	c.block.Encrypt(out, block)
+	return out
+}
+
+// encrypt encrypts a single block with Crypto if set, or with K otherwise.
+// The AES cipher for K is cached in m until K changes.
+func (m *Milenage) encrypt(plain []byte) ([]byte, error) {
+	if m.Crypto != nil {
+		return m.Crypto.Encrypt(plain), nil
+	}
+
+	if m.block == nil || !bytes.Equal(m.blockKey, m.K) {
+		block, err := aes.NewCipher(m.K)
+		if err != nil {
+			return nil, err
+		}
+		m.block, m.blockKey = block, bytes.Clone(m.K)
+	}
+
+	encrypted := make([]byte, len(plain))
+	m.block.Encrypt(encrypted, plain)
+	return encrypted, nil
+}
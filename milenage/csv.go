@@ -0,0 +1,131 @@
+package milenage
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvChunkRows is the number of rows per worker read ahead by BatchComputeCSV
+// before computing them, which bounds the memory regardless of the number of rows.
+const csvChunkRows = 256
+
+// BatchComputeCSV reads Input from r in CSV, computes vectors with BatchCompute, and writes
+// them to w in CSV, keeping the order of the rows.
+//
+// Each input row is "k,op,opc,rand,sqn,amf" in hex, with either op or opc left empty.
+// A header row starting with "k" is skipped. The output rows are
+// "rand,sqn,amf,mac_a,xres,ck,ik,ak,autn" in hex, preceded by a header row.
+//
+// The rows are streamed in chunks, so only a fixed number of them are held in memory at once.
+func BatchComputeCSV(r io.Reader, w io.Writer, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 6
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"rand", "sqn", "amf", "mac_a", "xres", "ck", "ik", "ak", "autn"}); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	var (
+		chunk = make([]Input, 0, workers*csvChunkRows)
+		line  = 0
+		eof   bool
+	)
+	for !eof {
+		chunk = chunk[:0]
+		for len(chunk) < cap(chunk) {
+			row, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				eof = true
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read CSV: %w", err)
+			}
+			line++
+			if line == 1 && strings.EqualFold(row[0], "k") {
+				continue
+			}
+
+			in, err := parseInputRow(row)
+			if err != nil {
+				return fmt.Errorf("invalid row at line %d: %w", line, err)
+			}
+			chunk = append(chunk, in)
+		}
+
+		vectors, err := BatchCompute(chunk, workers)
+		if err != nil {
+			return err
+		}
+		for _, v := range vectors {
+			if err := cw.Write([]string{
+				hex.EncodeToString(v.RAND),
+				hex.EncodeToString(v.SQN),
+				hex.EncodeToString(v.AMF),
+				hex.EncodeToString(v.MACA),
+				hex.EncodeToString(v.XRES),
+				hex.EncodeToString(v.CK),
+				hex.EncodeToString(v.IK),
+				hex.EncodeToString(v.AK),
+				hex.EncodeToString(v.AUTN),
+			}); err != nil {
+				return fmt.Errorf("failed to write CSV: %w", err)
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseInputRow parses a row of "k,op,opc,rand,sqn,amf" into Input.
+func parseInputRow(row []string) (Input, error) {
+	var (
+		in     Input
+		fields = []struct {
+			name string
+			dst  *[]byte
+		}{
+			{"K", &in.K}, {"OP", &in.OP}, {"OPc", &in.OPc}, {"RAND", &in.RAND},
+		}
+	)
+	for i, f := range fields {
+		if row[i] == "" {
+			continue
+		}
+		b, err := hex.DecodeString(row[i])
+		if err != nil {
+			return Input{}, fmt.Errorf("invalid %s: %w", f.name, err)
+		}
+		*f.dst = b
+	}
+	if in.OP == nil && in.OPc == nil {
+		return Input{}, fmt.Errorf("either OP or OPc should be given")
+	}
+
+	sqn, err := strconv.ParseUint(row[4], 16, 48)
+	if err != nil {
+		return Input{}, fmt.Errorf("invalid SQN: %w", err)
+	}
+	amf, err := strconv.ParseUint(row[5], 16, 16)
+	if err != nil {
+		return Input{}, fmt.Errorf("invalid AMF: %w", err)
+	}
+	in.SQN = sqn
+	in.AMF = uint16(amf)
+	return in, nil
+}
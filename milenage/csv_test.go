@@ -0,0 +1,105 @@
+package milenage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBatchComputeCSVStreaming(t *testing.T) {
+	const (
+		workers = 2
+		k       = "00112233445566778899aabbccddeeff"
+		opc     = "62e75b8d6fa5bf46ec87a9276f9df54d"
+	)
+	// more than two chunks, the last of which is not full
+	rows := 2*workers*csvChunkRows + 7
+
+	var in strings.Builder
+	in.WriteString("k,op,opc,rand,sqn,amf\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&in, "%s,,%s,%032x,%x,8000\n", k, opc, i, i)
+	}
+
+	var out bytes.Buffer
+	if err := BatchComputeCSV(strings.NewReader(in.String()), &out, workers); err != nil {
+		t.Fatalf("BatchComputeCSV() failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read the output: %v", err)
+	}
+	if len(records) != rows+1 {
+		t.Fatalf("got %d rows, want %d and the header", len(records)-1, rows)
+	}
+
+	for i, r := range records[1:] {
+		if want := fmt.Sprintf("%032x", i); r[0] != want {
+			t.Fatalf("RAND of row %d = %s, want %s", i, r[0], want)
+		}
+
+		m := NewWithOPc(mustHex(t, k), mustHex(t, opc), mustHex(t, r[0]), uint64(i), 0x8000)
+		macA, err := m.F1()
+		if err != nil {
+			t.Fatalf("F1() failed: %v", err)
+		}
+		if got := r[3]; got != hex.EncodeToString(macA) {
+			t.Fatalf("MAC-A of row %d = %s, want %x", i, got, macA)
+		}
+	}
+}
+
+// rowReader generates n input rows for BatchComputeCSV on the fly, so that the input
+// itself doesn't take memory, and records the peak heap seen while they are read.
+type rowReader struct {
+	n, i int
+	buf  []byte
+	peak uint64
+}
+
+func (r *rowReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.i == r.n {
+			return 0, io.EOF
+		}
+		if r.i%csvChunkRows == 0 {
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			r.peak = max(r.peak, ms.HeapAlloc)
+		}
+		r.buf = fmt.Appendf(r.buf[:0], "00112233445566778899aabbccddeeff,,62e75b8d6fa5bf46ec87a9276f9df54d,%032x,%x,8000\n", r.i, r.i)
+		r.i++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestBatchComputeCSVMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+
+	peak := func(rows int) uint64 {
+		runtime.GC()
+		r := &rowReader{n: rows}
+		if err := BatchComputeCSV(r, io.Discard, 4); err != nil {
+			t.Fatalf("BatchComputeCSV() failed: %v", err)
+		}
+		return r.peak
+	}
+
+	// 16 times the rows should not take more memory at a time, as they are
+	// streamed in chunks; a little slack is left for the GC pacing
+	small, large := peak(4*csvChunkRows*4), peak(64*csvChunkRows*4)
+	if large > small*2 {
+		t.Errorf("peak heap = %d bytes for %d rows, want about %d bytes as for %d rows",
+			large, 64*csvChunkRows*4, small, 4*csvChunkRows*4)
+	}
+}
@@ -0,0 +1,37 @@
+package milenage
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+// Diff compares m with other field by field, and returns the hex of both values
+// (m's first) for each field that differs, indexed by the field name.
+// It returns an empty map if all the fields are the same.
+func (m *Milenage) Diff(other *Milenage) map[string][2]string {
+	diff := make(map[string][2]string)
+	for _, f := range []struct {
+		name string
+		a, b []byte
+	}{
+		{"K", m.K, other.K},
+		{"OP", m.OP, other.OP},
+		{"OPc", m.OPc, other.OPc},
+		{"RAND", m.RAND, other.RAND},
+		{"SQN", m.SQN, other.SQN},
+		{"AMF", m.AMF, other.AMF},
+		{"MACA", m.MACA, other.MACA},
+		{"MACS", m.MACS, other.MACS},
+		{"RES", m.RES, other.RES},
+		{"CK", m.CK, other.CK},
+		{"IK", m.IK, other.IK},
+		{"AK", m.AK, other.AK},
+		{"AKS", m.AKS, other.AKS},
+		{"RESStar", m.RESStar, other.RESStar},
+	} {
+		if !bytes.Equal(f.a, f.b) {
+			diff[f.name] = [2]string{hex.EncodeToString(f.a), hex.EncodeToString(f.b)}
+		}
+	}
+	return diff
+}
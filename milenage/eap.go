@@ -0,0 +1,142 @@
+package milenage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// ComputeCKIKPrime computes CK' and IK' for EAP-AKA' (A.2, TS 33.402 and 3.3, RFC 5448)
+// from CK, IK, SQN and AK in m and the access network identity (e.g. "WLAN").
+//
+// Note that this function should be called after F2345 to compute CK, IK and AK.
+func (m *Milenage) ComputeCKIKPrime(networkName []byte) (ckPrime, ikPrime []byte, err error) {
+	if err := m.validateLength(); err != nil {
+		return nil, nil, err
+	}
+	if len(networkName) == 0 {
+		return nil, nil, fmt.Errorf("network name should not be empty")
+	}
+
+	out := KDF(m.DerivationKey(), 0x20, networkName, xor(m.SQN, m.AK))
+	return out[:16], out[16:], nil
+}
+
+// Lengths of the keys derived from MK for EAP-AKA' (3.3, RFC 5448).
+const (
+	eapKEncrLen = 16
+	eapKAutLen  = 32
+	eapKReLen   = 32
+	eapMSKLen   = 64
+	eapEMSKLen  = 64
+)
+
+// DeriveEAPAKAPrimeKeys derives the EAP-AKA' keys from CK', IK' (see ComputeCKIKPrime)
+// and the identity of the peer as described in 3.3, RFC 5448, i.e. expands
+// MK = PRF'(IK'|CK', "EAP-AKA'"|Identity) into K_encr, K_aut, K_re, MSK and EMSK.
+func DeriveEAPAKAPrimeKeys(ckPrime, ikPrime []byte, identity string) (kEncr, kAut, kRe, msk, emsk []byte, err error) {
+	if len(ckPrime) != 16 {
+		return nil, nil, nil, nil, nil, fmt.Errorf("%w: length of CK' should be %d, got: %d", ErrInvalidKeyLength, 16, len(ckPrime))
+	}
+	if len(ikPrime) != 16 {
+		return nil, nil, nil, nil, nil, fmt.Errorf("%w: length of IK' should be %d, got: %d", ErrInvalidKeyLength, 16, len(ikPrime))
+	}
+
+	key := concat(ikPrime, ckPrime)
+	mk := prfPrime(key, []byte("EAP-AKA'"+identity), eapKEncrLen+eapKAutLen+eapKReLen+eapMSKLen+eapEMSKLen)
+
+	kEncr, mk = mk[:eapKEncrLen], mk[eapKEncrLen:]
+	kAut, mk = mk[:eapKAutLen], mk[eapKAutLen:]
+	kRe, mk = mk[:eapKReLen], mk[eapKReLen:]
+	msk, emsk = mk[:eapMSKLen], mk[eapMSKLen:]
+	return kEncr, kAut, kRe, msk, emsk, nil
+}
+
+// prfPrime is PRF' of RFC 5448 (3.4.1), i.e. T1 | T2 | ... with
+// T1 = HMAC-SHA-256(K, S | 0x01) and Tn = HMAC-SHA-256(K, Tn-1 | S | n), truncated to length.
+func prfPrime(key, s []byte, length int) []byte {
+	var (
+		out []byte
+		t   []byte
+	)
+	for n := byte(1); len(out) < length; n++ {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(t)
+		mac.Write(s)
+		mac.Write([]byte{n})
+		t = mac.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
+
+// Layout of EAP-AKA' packets (8.1, RFC 4187) used to locate AT_MAC.
+const (
+	eapHeaderLen = 8 // Code, Identifier, Length, Type, Subtype and Reserved
+	atMAC        = 11
+	atMACLen     = 20 // Type, Length, Reserved and the 16-byte MAC
+	atMACSize    = 16
+)
+
+// ComputeATMAC computes the value of AT_MAC in the EAP-AKA' packet given with K_aut
+// (see DeriveEAPAKAPrimeKeys), i.e. HMAC-SHA-256-128 over the whole packet with the MAC
+// field of AT_MAC zeroed (3.4.1, RFC 5448 and 10.15, RFC 4187).
+//
+// The packet should contain AT_MAC; its current value is ignored, and the packet is not modified.
+func ComputeATMAC(kAut, eapPacket []byte) ([]byte, error) {
+	if len(kAut) != 32 {
+		return nil, fmt.Errorf("%w: length of K_aut should be %d, got: %d", ErrInvalidKeyLength, 32, len(kAut))
+	}
+	offset, err := findATMAC(eapPacket)
+	if err != nil {
+		return nil, err
+	}
+
+	p := append([]byte{}, eapPacket...)
+	clear(p[offset : offset+atMACSize])
+
+	mac := hmac.New(sha256.New, kAut)
+	mac.Write(p)
+	return mac.Sum(nil)[:atMACSize], nil
+}
+
+// VerifyATMAC reports whether the value of AT_MAC in the EAP-AKA' packet given is the one
+// computed by ComputeATMAC with K_aut. The comparison is constant-time.
+func VerifyATMAC(kAut, eapPacket []byte) (bool, error) {
+	expected, err := ComputeATMAC(kAut, eapPacket)
+	if err != nil {
+		return false, err
+	}
+
+	offset, _ := findATMAC(eapPacket)
+	return hmac.Equal(expected, eapPacket[offset:offset+atMACSize]), nil
+}
+
+// findATMAC returns the offset of the MAC field of AT_MAC in the EAP-AKA' packet.
+func findATMAC(p []byte) (int, error) {
+	if len(p) < eapHeaderLen {
+		return 0, fmt.Errorf("EAP packet is too short: %d", len(p))
+	}
+	if l := int(binary.BigEndian.Uint16(p[2:4])); l != len(p) {
+		return 0, fmt.Errorf("length of EAP packet should be %d as in the header, got: %d", l, len(p))
+	}
+
+	for i := eapHeaderLen; i < len(p); {
+		if i+2 > len(p) {
+			return 0, fmt.Errorf("truncated attribute at %d", i)
+		}
+		l := int(p[i+1]) * 4
+		if l == 0 || i+l > len(p) {
+			return 0, fmt.Errorf("invalid length of attribute %d at %d: %d", p[i], i, l)
+		}
+		if p[i] == atMAC {
+			if l != atMACLen {
+				return 0, fmt.Errorf("length of AT_MAC should be %d, got: %d", atMACLen, l)
+			}
+			return i + 4, nil
+		}
+		i += l
+	}
+	return 0, fmt.Errorf("AT_MAC not found in EAP packet")
+}
@@ -0,0 +1,16 @@
+package milenage
+
+import "errors"
+
+// Errors returned by the functions in this package, wrapped with the details,
+// so that callers can tell the failures apart with errors.Is.
+var (
+	// ErrInvalidKeyLength is returned if K, OP, OPc or a derived key has a wrong length.
+	ErrInvalidKeyLength = errors.New("invalid key length")
+	// ErrInvalidRANDLength is returned if RAND is not 16 bytes long.
+	ErrInvalidRANDLength = errors.New("invalid RAND length")
+	// ErrMACMismatch is returned if MAC-A in AUTN or MAC-S in AUTS is not the one expected.
+	ErrMACMismatch = errors.New("MAC mismatch")
+	// ErrInvalidSNN is returned if the serving network name or its MCC or MNC is malformed.
+	ErrInvalidSNN = errors.New("invalid serving network name")
+)
@@ -0,0 +1,54 @@
+package milenage
+
+import "fmt"
+
+// Responses computes RES with F2345 and returns the response in the lengths consumed
+// by each generation at once: the 32-bit SRES for GSM, the 64-bit RES for UMTS and
+// the 128-bit RES* for 5G with the serving network given by mcc and mnc.
+func (m *Milenage) Responses(mcc, mnc string) (sres, res, resStar []byte, err error) {
+	res, _, _, _, err = m.F2345()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("F2345() failed: %w", err)
+	}
+
+	resStar, err = m.ComputeRESStar(mcc, mnc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("ComputeRESStar() failed: %w", err)
+	}
+
+	return c2(res), res, resStar, nil
+}
+
+// c2 is the conversion function c2 from RES to SRES (6.8.1.2, TS 33.102),
+// i.e. the XOR of the 32-bit words of RES padded with zeros to 128 bits.
+func c2(res []byte) []byte {
+	sres := make([]byte, 4)
+	for i, b := range res {
+		sres[i%4] ^= b
+	}
+	return sres
+}
+
+// ComputeGSMTriplet computes RES, CK and IK with F2345 and converts them into SRES and Kc
+// of the GSM triplet with the conversion functions c2 and c3 (6.8.1.2, TS 33.102),
+// for a USIM used on GSM. RAND of the triplet is the one in m.
+func (m *Milenage) ComputeGSMTriplet() (sres [4]byte, kc [8]byte, err error) {
+	res, ck, ik, _, err := m.F2345()
+	if err != nil {
+		return sres, kc, fmt.Errorf("F2345() failed: %w", err)
+	}
+
+	copy(sres[:], c2(res))
+	copy(kc[:], c3(ck, ik))
+	return sres, kc, nil
+}
+
+// c3 is the conversion function c3 from CK and IK to Kc (6.8.1.2, TS 33.102),
+// i.e. CK1 xor CK2 xor IK1 xor IK2 with each of them the 64-bit halves.
+func c3(ck, ik []byte) []byte {
+	kc := make([]byte, 8)
+	for i := range kc {
+		kc[i] = ck[i] ^ ck[i+8] ^ ik[i] ^ ik[i+8]
+	}
+	return kc
+}
@@ -0,0 +1,141 @@
+package milenage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// HexBytes is a byte slice encoded as a hex string in JSON.
+// An empty string is decoded into nil.
+type HexBytes []byte
+
+// MarshalText encodes h in lowercase hex.
+func (h HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+// UnmarshalText decodes b in hex into h.
+func (h *HexBytes) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*h = nil
+		return nil
+	}
+
+	v, err := hex.DecodeString(string(b))
+	if err != nil {
+		return err
+	}
+	*h = v
+	return nil
+}
+
+type milenageJSON struct {
+	K       HexBytes `json:"k"`
+	OP      HexBytes `json:"op"`
+	OPc     HexBytes `json:"opc"`
+	RAND    HexBytes `json:"rand"`
+	SQN     HexBytes `json:"sqn"`
+	AMF     HexBytes `json:"amf"`
+	MACA    HexBytes `json:"maca"`
+	MACS    HexBytes `json:"macs"`
+	RES     HexBytes `json:"res"`
+	CK      HexBytes `json:"ck"`
+	IK      HexBytes `json:"ik"`
+	AK      HexBytes `json:"ak"`
+	AKS     HexBytes `json:"aks"`
+	RESStar HexBytes `json:"resStar"`
+}
+
+// MarshalJSON encodes all the fields of Milenage as hex strings.
+func (m *Milenage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&milenageJSON{
+		K:       m.K,
+		OP:      m.OP,
+		OPc:     m.OPc,
+		RAND:    m.RAND,
+		SQN:     m.SQN,
+		AMF:     m.AMF,
+		MACA:    m.MACA,
+		MACS:    m.MACS,
+		RES:     m.RES,
+		CK:      m.CK,
+		IK:      m.IK,
+		AK:      m.AK,
+		AKS:     m.AKS,
+		RESStar: m.RESStar,
+	})
+}
+
+// UnmarshalJSON decodes Milenage encoded by MarshalJSON.
+func (m *Milenage) UnmarshalJSON(b []byte) error {
+	var v milenageJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*m = Milenage{
+		K:       v.K,
+		OP:      v.OP,
+		OPc:     v.OPc,
+		RAND:    v.RAND,
+		SQN:     v.SQN,
+		AMF:     v.AMF,
+		MACA:    v.MACA,
+		MACS:    v.MACS,
+		RES:     v.RES,
+		CK:      v.CK,
+		IK:      v.IK,
+		AK:      v.AK,
+		AKS:     v.AKS,
+		RESStar: v.RESStar,
+	}
+	return nil
+}
+
+type vectorJSON struct {
+	RAND HexBytes `json:"rand"`
+	SQN  HexBytes `json:"sqn"`
+	AMF  HexBytes `json:"amf"`
+	MACA HexBytes `json:"maca"`
+	XRES HexBytes `json:"xres"`
+	CK   HexBytes `json:"ck"`
+	IK   HexBytes `json:"ik"`
+	AK   HexBytes `json:"ak"`
+	AUTN HexBytes `json:"autn"`
+}
+
+// MarshalJSON encodes all the fields of Vector as hex strings.
+func (v Vector) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&vectorJSON{
+		RAND: v.RAND,
+		SQN:  v.SQN,
+		AMF:  v.AMF,
+		MACA: v.MACA,
+		XRES: v.XRES,
+		CK:   v.CK,
+		IK:   v.IK,
+		AK:   v.AK,
+		AUTN: v.AUTN,
+	})
+}
+
+// UnmarshalJSON decodes Vector encoded by MarshalJSON.
+func (v *Vector) UnmarshalJSON(b []byte) error {
+	var j vectorJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	*v = Vector{
+		RAND: j.RAND,
+		SQN:  j.SQN,
+		AMF:  j.AMF,
+		MACA: j.MACA,
+		XRES: j.XRES,
+		CK:   j.CK,
+		IK:   j.IK,
+		AK:   j.AK,
+		AUTN: j.AUTN,
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package milenage
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMilenageJSON(t *testing.T) {
+	m := newTestMilenage(t)
+	m.OP = mustHex(t, "cdc202d5123e20f62b6d676ac72cb318")
+	if _, err := m.F1(); err != nil {
+		t.Fatalf("F1() failed: %v", err)
+	}
+	if _, err := m.F1Star(m.SQN, []byte{0x00, 0x00}); err != nil {
+		t.Fatalf("F1Star() failed: %v", err)
+	}
+	if _, _, _, _, err := m.F2345(); err != nil {
+		t.Fatalf("F2345() failed: %v", err)
+	}
+	if _, err := m.F5Star(); err != nil {
+		t.Fatalf("F5Star() failed: %v", err)
+	}
+	resStar, err := m.ComputeRESStar("001", "01")
+	if err != nil {
+		t.Fatalf("ComputeRESStar() failed: %v", err)
+	}
+	m.RESStar = resStar
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	var got Milenage
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s) failed: %v", b, err)
+	}
+
+	// only the exported fields are encoded
+	want := Milenage{
+		K: m.K, OP: m.OP, OPc: m.OPc, RAND: m.RAND, SQN: m.SQN, AMF: m.AMF,
+		MACA: m.MACA, MACS: m.MACS, RES: m.RES, CK: m.CK, IK: m.IK, AK: m.AK,
+		AKS: m.AKS, RESStar: m.RESStar,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json.Unmarshal(%s) = %+v, want %+v", b, got, want)
+	}
+}
+
+func TestMilenageJSONEmpty(t *testing.T) {
+	var got Milenage
+	if err := json.Unmarshal([]byte(`{"k":"00112233445566778899aabbccddeeff","op":""}`), &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	if got.OP != nil || got.RAND != nil {
+		t.Errorf("OP = %x, RAND = %x, want nil for an empty or missing value", got.OP, got.RAND)
+	}
+
+	if err := json.Unmarshal([]byte(`{"k":"xyz"}`), &got); err == nil {
+		t.Error("json.Unmarshal() with K not in hex = nil, want an error")
+	}
+}
+
+func TestVectorJSON(t *testing.T) {
+	v, err := ComputeVector(
+		mustHex(t, "00112233445566778899aabbccddeeff"),
+		mustHex(t, "62e75b8d6fa5bf46ec87a9276f9df54d"),
+		mustHex(t, "00112233445566778899aabbccddeeff"),
+		1, 0x8000)
+	if err != nil {
+		t.Fatalf("ComputeVector() failed: %v", err)
+	}
+
+	// Vector is encoded both as a value and through a pointer
+	for _, in := range []any{v, &v} {
+		b, err := json.Marshal(in)
+		if err != nil {
+			t.Fatalf("json.Marshal() failed: %v", err)
+		}
+		var got Vector
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("json.Unmarshal(%s) failed: %v", b, err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("json.Unmarshal(%s) = %+v, want %+v", b, got, v)
+		}
+	}
+}
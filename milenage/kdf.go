@@ -0,0 +1,41 @@
+package milenage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// KDF is the generic key derivation function of Annex B.2, TS 33.220, which is used
+// for all the key derivations in TS 33.401 and TS 33.501, i.e. HMAC-SHA-256 keyed
+// with key over the input string S built by KDFInput. It returns the 32-byte output.
+func KDF(key []byte, fc byte, params ...[]byte) []byte {
+	return KDFWithHash(sha256.New, key, fc, params...)
+}
+
+// KDFWithHash is KDF with HMAC using the hash function given instead of SHA-256.
+//
+// NOT COMPLIANT WITH TS 33.220 unless sha256.New is given.
+func KDFWithHash(h func() hash.Hash, key []byte, fc byte, params ...[]byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(KDFInput(fc, params...))
+	return mac.Sum(nil)
+}
+
+// KDFInput builds the input string S to KDF (B.2.0, TS 33.220),
+// i.e. FC || P0 || L0 || P1 || L1 || ... with each Li the 2-byte length of Pi.
+func KDFInput(fc byte, params ...[]byte) []byte {
+	l := 1
+	for _, p := range params {
+		l += len(p) + 2
+	}
+
+	s := make([]byte, 0, l)
+	s = append(s, fc)
+	for _, p := range params {
+		s = append(s, p...)
+		s = binary.BigEndian.AppendUint16(s, uint16(len(p)))
+	}
+	return s
+}
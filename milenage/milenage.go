@@ -11,17 +11,25 @@ and some helpers to be used during the authentication procedure.
 package milenage
 
 import (
+	"bytes"
 	"crypto/aes"
+	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 )
 
 // Milenage is a set of parameters used/generated in MILENAGE algorithm.
 type Milenage struct {
 	// K is a 128-bit subscriber key that is an input to the functions f1, f1*, f2, f3, f4, f5 and f5*.
+	//
+	// A 256-bit K is also accepted, in which case AES-256 is used as the kernel function.
+	// NOT COMPLIANT WITH TS 35.206, which specifies 128-bit K; this is for research only.
 	K []byte
 	// OP is a 128-bit Operator Variant Algorithm Configuration Field that is a component of the
 	// functions f1, f1*, f2, f3, f4, f5 and f5*.
@@ -55,6 +63,27 @@ type Milenage struct {
 
 	// RESStar or RES* is a 128-bit response that is used in 5G.
 	RESStar []byte
+
+	// Crypto is used instead of the built-in AES-128 with K for the kernel function
+	// if set, e.g. to delegate it to an HSM that holds K. K can be left nil then.
+	Crypto ExternalCrypto
+
+	// trace is called with the intermediate values during computation if set.
+	trace func(name string, value []byte)
+
+	// aksInput is K || OPc || RAND that AKS was last computed with by F5Star.
+	aksInput []byte
+	// akInput is K || OPc || RAND that AK was last computed with by F2345.
+	akInput []byte
+	// tempValue is TEMP that was last computed with tempInput, K || OPc || RAND,
+	// so that f1, f1* and f2-f5* share it and it's traced once per RAND.
+	tempValue []byte
+	tempInput []byte
+
+	// block is AES keyed with blockKey, cached so that the key schedule runs once
+	// per K instead of on every block encrypted. It's recreated if K changes.
+	block    cipher.Block
+	blockKey []byte
 }
 
 // New initializes a new MILENAGE algorithm.
@@ -115,17 +144,84 @@ func NewWithOPc(k, opc, rand []byte, sqn uint64, amf uint16) *Milenage {
 	return m
 }
 
+// Clone returns a deep copy of m, in which no byte slice aliases the one in m,
+// so that either of them can be modified without affecting the other.
+//
+// Crypto and the function set with SetTrace are shared.
+func (m *Milenage) Clone() *Milenage {
+	return &Milenage{
+		K:         bytes.Clone(m.K),
+		OP:        bytes.Clone(m.OP),
+		OPc:       bytes.Clone(m.OPc),
+		RAND:      bytes.Clone(m.RAND),
+		SQN:       bytes.Clone(m.SQN),
+		AMF:       bytes.Clone(m.AMF),
+		MACA:      bytes.Clone(m.MACA),
+		MACS:      bytes.Clone(m.MACS),
+		RES:       bytes.Clone(m.RES),
+		CK:        bytes.Clone(m.CK),
+		IK:        bytes.Clone(m.IK),
+		AK:        bytes.Clone(m.AK),
+		AKS:       bytes.Clone(m.AKS),
+		RESStar:   bytes.Clone(m.RESStar),
+		Crypto:    m.Crypto,
+		trace:     m.trace,
+		aksInput:  bytes.Clone(m.aksInput),
+		akInput:   bytes.Clone(m.akInput),
+		tempValue: bytes.Clone(m.tempValue),
+		tempInput: bytes.Clone(m.tempInput),
+		block:     m.block,
+		blockKey:  bytes.Clone(m.blockKey),
+	}
+}
+
+// New5GWithOPAndOPc is NewWithOPc, but also takes OP and returns an error if opc is
+// not the one derived from k and op, which catches the OPc of another subscriber
+// provisioned by mistake.
+func New5GWithOPAndOPc(k, op, opc, rand []byte, sqn uint64, amf uint16) (*Milenage, error) {
+	expected, err := ComputeOPc(k, op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute OPc: %w", err)
+	}
+	if !bytes.Equal(expected, opc) {
+		return nil, fmt.Errorf("OPc doesn't match K and OP: expected %x, got %x", expected, opc)
+	}
+
+	m := NewWithOPc(k, opc, rand, sqn, amf)
+	m.OP = op
+	return m, nil
+}
+
 // ComputeOPc is a helper that provides users to retrieve OPc value from
 // the K and OP given.
+//
+// It's meant to be called for every subscriber in bulk provisioning, so it computes
+// OPc directly instead of going through a Milenage, allocating only the cipher and OPc.
 func ComputeOPc(k, op []byte) ([]byte, error) {
-	m := New(k, op, make([]byte, 16), 0, 0)
-	if err := m.computeOPc(); err != nil {
+	if err := validateK(k); err != nil {
+		return nil, err
+	}
+	if len(op) != 16 {
+		return nil, fmt.Errorf("%w: length of OP should be %d, got: %d", ErrInvalidKeyLength, 16, len(op))
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
 		return nil, err
 	}
-	return m.OPc, nil
+
+	opc := make([]byte, 16)
+	block.Encrypt(opc, op)
+	for i := range opc {
+		opc[i] ^= op[i]
+	}
+	return opc, nil
 }
 
 // ComputeAll fills all the fields in *Milenage struct.
+//
+// MACS is computed by f1* with m.AMF as in the conformance test data (TS 35.208),
+// not the MAC-S in AUTS, which uses AMF=0x0000; see GenerateAUTS for that.
 func (m *Milenage) ComputeAll() error {
 	if err := m.validateLength(); err != nil {
 		return err
@@ -147,6 +243,34 @@ func (m *Milenage) ComputeAll() error {
 		return fmt.Errorf("F5Star() failed: %w", err)
 	}
 
+	return m.AssertOutputLengths()
+}
+
+// AssertOutputLengths returns an error if any of the outputs in Milenage doesn't have
+// the length specified, which would otherwise be silently propagated to the keys.
+//
+// RESStar may also be empty, as it's not computed by ComputeAll.
+func (m *Milenage) AssertOutputLengths() error {
+	for _, f := range []struct {
+		name  string
+		value []byte
+		size  int
+	}{
+		{"MACA", m.MACA, 8},
+		{"MACS", m.MACS, 8},
+		{"RES", m.RES, 8},
+		{"CK", m.CK, 16},
+		{"IK", m.IK, 16},
+		{"AK", m.AK, 6},
+		{"AKS", m.AKS, 6},
+	} {
+		if len(f.value) != f.size {
+			return fmt.Errorf("length of %s should be %d, got: %d", f.name, f.size, len(f.value))
+		}
+	}
+	if l := len(m.RESStar); l != 0 && l != 16 {
+		return fmt.Errorf("length of RESStar should be %d, got: %d", 16, l)
+	}
 	return nil
 }
 
@@ -168,7 +292,9 @@ func (m *Milenage) F1() ([]byte, error) {
 // sequence number SQN and authentication management field AMF.
 //
 // Note that the AMF value should be zero to be compliant with the specification
-// TS 33.102 6.3.3 (This method just computes with the given value).
+// TS 33.102 6.3.3 (This method just computes with the given value). GenerateAUTS and
+// ParseAUTS compute MAC-S with AMF=0x0000 themselves without going through F1Star,
+// so m.MACS is only set by F1Star.
 func (m *Milenage) F1Star(sqn, amf []byte) ([]byte, error) {
 	mac, err := m.f1base(sqn, amf)
 	if err != nil {
@@ -192,15 +318,11 @@ func (m *Milenage) F2345() (res, ck, ik, ak []byte, err error) {
 		}
 	}
 
-	rijndaelInput := make([]byte, 16)
-	for i := 0; i < 16; i++ {
-		rijndaelInput[i] = m.RAND[i] ^ m.OPc[i]
-	}
-
-	temp, err := encrypt(m.K, rijndaelInput)
+	temp, err := m.temp()
 	if err != nil {
 		return
 	}
+	rijndaelInput := make([]byte, 16)
 
 	// To obtain output block OUT2: XOR OPc and TEMP, rotate by r2=0, and XOR on the
 	// constant c2 (which is all zeroes except that the last bit is 1).
@@ -209,11 +331,12 @@ func (m *Milenage) F2345() (res, ck, ik, ak []byte, err error) {
 	}
 	rijndaelInput[15] ^= 1
 
-	out, err := encrypt(m.K, rijndaelInput)
+	out, err := m.encrypt(rijndaelInput)
 	if err != nil {
 		return
 	}
 	tmp := xor(out, m.OPc)
+	m.traceValue("OUT2", tmp)
 	res = tmp[8:]
 	ak = tmp[:6]
 
@@ -224,11 +347,12 @@ func (m *Milenage) F2345() (res, ck, ik, ak []byte, err error) {
 	}
 	rijndaelInput[15] ^= 2
 
-	out, err = encrypt(m.K, rijndaelInput)
+	out, err = m.encrypt(rijndaelInput)
 	if err != nil {
 		return
 	}
 	ck = xor(out, m.OPc)
+	m.traceValue("OUT3", ck)
 
 	// To obtain output block OUT4: XOR OPc and TEMP, rotate by r4=64, and XOR on the
 	// constant c4 (which is all zeroes except that the 2nd from last bit is 1).
@@ -238,16 +362,18 @@ func (m *Milenage) F2345() (res, ck, ik, ak []byte, err error) {
 	}
 	rijndaelInput[15] ^= 4
 
-	out, err = encrypt(m.K, rijndaelInput)
+	out, err = m.encrypt(rijndaelInput)
 	if err != nil {
 		return
 	}
 	ik = xor(out, m.OPc)
+	m.traceValue("OUT4", ik)
 
 	m.RES = res
 	m.CK = ck
 	m.IK = ik
 	m.AK = ak
+	m.akInput = concat(m.K, m.OPc, m.RAND)
 	return res, ck, ik, ak, nil
 }
 
@@ -264,15 +390,11 @@ func (m *Milenage) F5Star() (aks []byte, err error) {
 		}
 	}
 
-	rijndaelInput := make([]byte, 16)
-	for i := 0; i < 16; i++ {
-		rijndaelInput[i] = m.RAND[i] ^ m.OPc[i]
-	}
-
-	tmp, err := encrypt(m.K, rijndaelInput)
+	tmp, err := m.temp()
 	if err != nil {
 		return
 	}
+	rijndaelInput := make([]byte, 16)
 
 	// To obtain output block OUT5: XOR OPc and TEMP, rotate by r5=96, and XOR on the
 	// constant c5 (which is all zeroes except that the 3rd from last bit is 1).
@@ -281,16 +403,34 @@ func (m *Milenage) F5Star() (aks []byte, err error) {
 	}
 	rijndaelInput[15] ^= 8
 
-	out, err := encrypt(m.K, rijndaelInput)
+	out, err := m.encrypt(rijndaelInput)
 	if err != nil {
 		return
 	}
 
-	aks = xor(out, m.OPc)[:6]
+	out5 := xor(out, m.OPc)
+	m.traceValue("OUT5", out5)
+
+	aks = out5[:6]
 	m.AKS = aks
+	m.aksInput = concat(m.K, m.OPc, m.RAND)
 	return aks, nil
 }
 
+// ResyncAK returns the resynch anonymity key AK* used to conceal SQN_MS in AUTS.
+//
+// It's the same as F5Star, except that the value computed previously is returned
+// as is if none of K, OPc and RAND has changed since then.
+func (m *Milenage) ResyncAK() ([]byte, error) {
+	if m.aksInput != nil && m.OPc != nil && bytes.Equal(m.aksInput, concat(m.K, m.OPc, m.RAND)) {
+		return m.AKS, nil
+	}
+	return m.F5Star()
+}
+
+// snnLen is the length of the serving network name built by ServingNetworkName.
+const snnLen = 32
+
 // ComputeRESStar computes RESStar from serving network name, RAND and RES
 // as described in A.4 RES* and XRES* derivation function, TS 33.501.
 //
@@ -301,36 +441,75 @@ func (m *Milenage) ComputeRESStar(mcc, mnc string) ([]byte, error) {
 		return nil, err
 	}
 
-	if len(mcc) != 3 {
-		return nil, fmt.Errorf("invalid MCC: %s", mcc)
+	snn, err := ServingNetworkName(mcc, mnc)
+	if err != nil {
+		return nil, err
+	}
+	return m.ComputeRESStarSNN(snn)
+}
+
+// ServingNetworkName returns the serving network name of a 5G network
+// (TS 24.501 9.12.1, TS 33.501 6.1.1.4), i.e. "5G:mnc<MNC>.mcc<MCC>.3gppnetwork.org".
+//
+// A 2-digit MNC is padded with a leading zero, so the result is always 32 bytes long
+// and the same for both the network and the UE.
+func ServingNetworkName(mcc, mnc string) (string, error) {
+	if len(mcc) != 3 || !isDigits(mcc) {
+		return "", fmt.Errorf("%w: invalid MCC: %s", ErrInvalidSNN, mcc)
+	}
+	if !isDigits(mnc) {
+		return "", fmt.Errorf("%w: invalid MNC: %s", ErrInvalidSNN, mnc)
 	}
 	if l := len(mnc); l == 2 {
 		mnc = "0" + mnc
 	} else if l != 3 {
-		return nil, fmt.Errorf("invalid MNC: %s", mnc)
+		return "", fmt.Errorf("%w: invalid MNC: %s", ErrInvalidSNN, mnc)
 	}
 
-	snn := []byte(fmt.Sprintf("5G:mnc%s.mcc%s.3gppnetwork.org", mnc, mcc))
-	if l := len(snn); l != 32 {
-		return nil, fmt.Errorf("failed to build SNN: %s", snn)
+	snn := fmt.Sprintf("5G:mnc%s.mcc%s.3gppnetwork.org", mnc, mcc)
+	if len(snn) != snnLen {
+		return "", fmt.Errorf("%w: length of SNN should be %d, got: %d", ErrInvalidSNN, snnLen, len(snn))
+	}
+	return snn, nil
+}
+
+// ValidateServingNetworkName checks that snn is well-formed as a serving network name
+// (TS 24.501 9.12.1), i.e. "5G:" followed by a non-empty SN Id or NAI realm of printable
+// ASCII, so that one with a realm other than 3gppnetwork.org can also be used.
+func ValidateServingNetworkName(snn string) error {
+	id, ok := strings.CutPrefix(snn, "5G:")
+	if !ok || id == "" {
+		return fmt.Errorf("%w: should be \"5G:\" followed by SN Id, got: %q", ErrInvalidSNN, snn)
+	}
+	if len(snn) > 0xffff {
+		return fmt.Errorf("%w: length of SNN should be up to %d, got: %d", ErrInvalidSNN, 0xffff, len(snn))
+	}
+	for i, c := range id {
+		if c <= ' ' || c > '~' {
+			return fmt.Errorf("%w: SNN should consist of printable ASCII, got %q at %d", ErrInvalidSNN, c, i+3)
+		}
 	}
+	return nil
+}
 
-	b := make([]byte, 63)
-	b[0] = 0x6b
+// ComputeRESStarSNN is ComputeRESStar with the serving network name given as is,
+// e.g. the one received with the challenge, instead of MCC and MNC. Any well-formed
+// name is accepted (see ValidateServingNetworkName), not only the 32-byte one
+// of ServingNetworkName.
+func (m *Milenage) ComputeRESStarSNN(servingNetworkName string) ([]byte, error) {
+	if err := m.validateLength(); err != nil {
+		return nil, err
+	}
 
-	copy(b[1:33], snn)
-	binary.BigEndian.PutUint16(b[33:35], uint16(len(snn)))
+	if err := ValidateServingNetworkName(servingNetworkName); err != nil {
+		return nil, err
+	}
 
-	copy(b[35:51], m.RAND)
-	binary.BigEndian.PutUint16(b[51:53], uint16(len(m.RAND)))
+	b := KDFInput(0x6b, []byte(servingNetworkName), m.RAND, m.RES)
 
-	copy(b[53:61], m.RES)
-	binary.BigEndian.PutUint16(b[61:63], uint16(len(m.RES)))
+	m.traceValue("S(RES*)", b)
 
-	k := make([]byte, 32)
-	copy(k[0:16], m.CK)
-	copy(k[16:32], m.IK)
-	mac := hmac.New(sha256.New, k)
+	mac := hmac.New(sha256.New, m.DerivationKey())
 	if _, err := mac.Write(b); err != nil {
 		return nil, fmt.Errorf("failed to compute RES*: %w", err)
 	}
@@ -339,6 +518,47 @@ func (m *Milenage) ComputeRESStar(mcc, mnc string) ([]byte, error) {
 	return out[len(out)-16:], nil
 }
 
+// DerivationKey returns CK || IK, the 256-bit key used in the key derivation
+// functions in TS 33.501 Annex A (e.g. RES* and KAUSF).
+//
+// The returned slice is always freshly allocated, so it never aliases CK or IK.
+func (m *Milenage) DerivationKey() []byte {
+	k := make([]byte, 32)
+	copy(k[0:16], m.CK)
+	copy(k[16:32], m.IK)
+	return k
+}
+
+// GetCK returns CK. The getters allow Milenage to be used as aka.AuthVectors.
+func (m *Milenage) GetCK() []byte { return m.CK }
+
+// GetIK returns IK.
+func (m *Milenage) GetIK() []byte { return m.IK }
+
+// GetAK returns AK.
+func (m *Milenage) GetAK() []byte { return m.AK }
+
+// GetSQN returns SQN.
+func (m *Milenage) GetSQN() []byte { return m.SQN }
+
+// GetRAND returns RAND.
+func (m *Milenage) GetRAND() []byte { return m.RAND }
+
+// GetRESStar returns RESStar.
+func (m *Milenage) GetRESStar() []byte { return m.RESStar }
+
+// CheckAK returns an error if AK was computed by F2345 with K, OPc or RAND other than
+// the current ones, e.g. RAND was changed without running F2345 again. AUTN and KAUSF
+// would then be bound to a stale SQN xor AK that the UE can't agree with.
+//
+// AK that was set directly instead of computed by F2345 is not checked.
+func (m *Milenage) CheckAK() error {
+	if m.akInput != nil && !bytes.Equal(m.akInput, concat(m.K, m.OPc, m.RAND)) {
+		return fmt.Errorf("AK is stale: K, OPc or RAND has changed since F2345() was called")
+	}
+	return nil
+}
+
 // GenerateAUTN generates AUTN uing the current values in Milenage
 // in the way described in 5.1.1.1, TS 33.105 and 6.3.2, TS 33.102.
 func (m *Milenage) GenerateAUTN() ([]byte, error) {
@@ -346,6 +566,21 @@ func (m *Milenage) GenerateAUTN() ([]byte, error) {
 		return nil, err
 	}
 
+	// SQN and AK are both 48 bits long. validateLength already covers this,
+	// but the check is repeated here as xor would otherwise silently truncate
+	// a longer AK (e.g. the whole OUT2 block) to the length of SQN.
+	if len(m.AK) != 6 {
+		return nil, fmt.Errorf("length of AK should be %d, got: %d", 6, len(m.AK))
+	}
+	if err := m.CheckAK(); err != nil {
+		return nil, err
+	}
+	// AK from f5 and AK* from f5* never match in practice, so they do only if
+	// one has bled into the other, which would make AUTN unverifiable by the UE.
+	if !isZero(m.AK) && bytes.Equal(m.AK, m.AKS) {
+		return nil, fmt.Errorf("AK is the same as AK*, AUTN should be concealed with AK from f5: %x", m.AK)
+	}
+
 	autn := make([]byte, 16)
 	copy(autn[0:6], xor(m.SQN, m.AK))
 	copy(autn[6:8], m.AMF)
@@ -353,19 +588,157 @@ func (m *Milenage) GenerateAUTN() ([]byte, error) {
 	return autn, nil
 }
 
+// GenerateAUTN3G generates AUTN for UMTS (3G) AKA in the way described in 6.3.2, TS 33.102.
+//
+// The layout of AUTN is the same as the one for EPS and 5G, but the "separation bit"
+// (bit 0 of AMF, TS 33.102 Annex H) should be 0 in UMTS as it's reserved for EPS and 5G,
+// so an error is returned if it's set.
+func (m *Milenage) GenerateAUTN3G() ([]byte, error) {
+	if len(m.AMF) == 2 && m.AMF[0]&0x80 != 0 {
+		return nil, fmt.Errorf("AMF separation bit should be 0 for 3G, got AMF: %x", m.AMF)
+	}
+	return m.GenerateAUTN()
+}
+
+// CheckSeparationBit reports whether the "separation bit" (bit 0 of AMF, TS 33.102 Annex H)
+// in AUTN is set, which it should be for EPS and 5G AKA and shouldn't for UMTS AKA.
+func CheckSeparationBit(autn []byte) (bool, error) {
+	if len(autn) != 16 {
+		return false, fmt.Errorf("length of AUTN should be %d, got: %d", 16, len(autn))
+	}
+	return autn[6]&0x80 != 0, nil
+}
+
+// UMTSQuintet runs F1, F2345 and GenerateAUTN3G, and returns the UMTS authentication
+// vector (6.3.2, TS 33.102), i.e. the quintet of RAND, XRES, CK, IK and AUTN.
+//
+// RAND, SQN and AMF should be set beforehand, with the separation bit of AMF being 0.
+func (m *Milenage) UMTSQuintet() (rand, xres, ck, ik, autn []byte, err error) {
+	if err := m.validateLength(); err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	if _, err := m.F1(); err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("F1() failed: %w", err)
+	}
+	xres, ck, ik, _, err = m.F2345()
+	if err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("F2345() failed: %w", err)
+	}
+	autn, err = m.GenerateAUTN3G()
+	if err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("GenerateAUTN3G() failed: %w", err)
+	}
+
+	return m.RAND, xres, ck, ik, autn, nil
+}
+
+// RecoverSQN recovers SQN from the SQN xor AK part of the AUTN given,
+// as the UE does on receiving an authentication challenge (6.3.3, TS 33.102).
+//
+// AK is computed from the current K and RAND with F2345 beforehand,
+// so RAND should be set to the one received along with AUTN.
+func (m *Milenage) RecoverSQN(autn []byte) ([]byte, error) {
+	if len(autn) != 16 {
+		return nil, fmt.Errorf("length of AUTN should be %d, got: %d", 16, len(autn))
+	}
+
+	return m.unmaskSQN(autn[0:6])
+}
+
+// VerifyAUTN verifies AUTN received with RAND as the UE does (6.3.3, TS 33.102), i.e.
+// recovers SQN with AK from F2345, recomputes MAC-A with it and the AMF in AUTN, and compares
+// it with the one in AUTN in constant time. On success, SQN, AMF and MACA are set in m.
+//
+// RAND should be set to the one received along with AUTN beforehand.
+// Note that SQN itself is not checked for freshness here.
+func (m *Milenage) VerifyAUTN(autn []byte) (bool, error) {
+	sqn, err := m.RecoverSQN(autn)
+	if err != nil {
+		return false, err
+	}
+	amf := autn[6:8]
+
+	out1, err := m.f1base(sqn, amf)
+	if err != nil {
+		return false, err
+	}
+	maca := out1[:8]
+	if !hmac.Equal(maca, autn[8:16]) {
+		return false, fmt.Errorf("%w: MAC-A mismatch: expected %x, got %x", ErrMACMismatch, maca, autn[8:16])
+	}
+
+	m.SQN = sqn
+	m.AMF = append([]byte{}, amf...)
+	m.MACA = maca
+	return true, nil
+}
+
+// SetSQNXorAK recovers SQN from the concealed SQN (SQN xor AK) given and stores it
+// in m.SQN, so that the rest of the values can be computed from the AUTN-derived data
+// when the raw SQN is not available.
+//
+// AK is computed from the current K and RAND with F2345 beforehand.
+func (m *Milenage) SetSQNXorAK(concealedSQN []byte) error {
+	if len(concealedSQN) != 6 {
+		return fmt.Errorf("length of SQN xor AK should be %d, got: %d", 6, len(concealedSQN))
+	}
+
+	sqn, err := m.unmaskSQN(concealedSQN)
+	if err != nil {
+		return err
+	}
+
+	m.SQN = sqn
+	return nil
+}
+
+// unmaskSQN computes AK and returns SQN xor-ed with it.
+func (m *Milenage) unmaskSQN(concealedSQN []byte) ([]byte, error) {
+	// This is the entry point of the UE side, where it's easy to forget to set
+	// RAND, so it's checked here with more context than validateLength gives.
+	if len(m.RAND) != 16 {
+		return nil, fmt.Errorf("%w: RAND from the Authentication Request must be set before recovering SQN: length of RAND should be %d, got: %d", ErrInvalidRANDLength, 16, len(m.RAND))
+	}
+
+	if _, _, _, _, err := m.F2345(); err != nil {
+		return nil, fmt.Errorf("F2345() failed: %w", err)
+	}
+	if len(m.AK) != 6 {
+		return nil, fmt.Errorf("length of AK should be %d, got: %d", 6, len(m.AK))
+	}
+
+	return xor(concealedSQN, m.AK), nil
+}
+
 // GenerateAUTS generates AUTS using the current values in Milenage
 // in the way described in 5.1.1.3, TS 33.105 and 6.3.3, TS 33.102.
 //
-// Note: MAC-S and AK-S are re-calculated with AMF=0x0000.
+// Note: MAC-S and AK-S are re-calculated with AMF=0x0000. m.AMF and m.MACS are left
+// untouched, so F1 keeps using the original AMF afterwards and MACS stays the one
+// computed by F1Star.
 func (m *Milenage) GenerateAUTS() ([]byte, error) {
+	return m.generateAUTS(m.SQN)
+}
+
+// ExpectedAUTS computes the AUTS that the UE should send if its SQN_MS is sqnMS,
+// using the current K and RAND, for the network side to compare with the received AUTS.
+// This helps tell whether a resynchronisation failure is due to SQN or to the keys.
+//
+// m.SQN is not modified.
+func (m *Milenage) ExpectedAUTS(sqnMS uint64) ([]byte, error) {
+	s := make([]byte, 8)
+	binary.BigEndian.PutUint64(s, sqnMS)
+	return m.generateAUTS(s[2:])
+}
+
+// generateAUTS assembles AUTS = SQN_MS xor AK* || MAC-S from the SQN_MS given.
+func (m *Milenage) generateAUTS(sqnMS []byte) ([]byte, error) {
 	if err := m.validateLength(); err != nil {
 		return nil, err
 	}
 
-	// The AMF used to calculate MAC-S assumes a dummy value of all
-	// zeros so that it does not need to be transmitted in the clear
-	// in the re-synch message (6.3.3, TS 33.102).
-	macS, err := m.F1Star(m.SQN, []byte{0x00, 0x00})
+	macS, err := m.resyncMAC(sqnMS)
 	if err != nil {
 		return nil, err
 	}
@@ -373,31 +746,99 @@ func (m *Milenage) GenerateAUTS() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	// See GenerateAUTN; AUTS is concealed with AK* from f5* instead.
+	if !isZero(aks) && bytes.Equal(aks, m.AK) {
+		return nil, fmt.Errorf("AK* is the same as AK, AUTS should be concealed with AK* from f5*: %x", aks)
+	}
 
 	auts := make([]byte, 14)
-	copy(auts[0:6], xor(m.SQN, aks))
+	copy(auts[0:6], xor(sqnMS, aks))
 	copy(auts[6:14], macS)
 
 	return auts, nil
 }
 
+// resyncMAC computes MAC-S in AUTS for sqnMS with f1* without setting m.MACS.
+//
+// The AMF used to calculate MAC-S assumes a dummy value of all
+// zeros so that it does not need to be transmitted in the clear
+// in the re-synch message (6.3.3, TS 33.102).
+func (m *Milenage) resyncMAC(sqnMS []byte) ([]byte, error) {
+	mac, err := m.f1base(sqnMS, []byte{0x00, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	return mac[8:], nil
+}
+
+// VerifyAUTS verifies AUTS sent by the UE on synchronisation failure, and returns
+// SQN_MS recovered from it, in the way described in 6.3.5, TS 33.102.
+//
+// The UE computes AK* and MAC-S with the RAND of the challenge it rejected, so
+// VerifyAUTS must be called on a Milenage whose RAND equals that RAND. The RAND of
+// the original challenge is given as rand to ensure this. MAC-S can't be verified
+// with another RAND, so a mismatch is reported as ErrMACMismatch.
+func (m *Milenage) VerifyAUTS(rand, auts []byte) ([]byte, error) {
+	if !bytes.Equal(m.RAND, rand) {
+		return nil, fmt.Errorf("%w: MAC-S is computed with RAND %x of the challenge, but RAND is %x", ErrMACMismatch, rand, m.RAND)
+	}
+
+	sqnMS, _, err := m.ParseAUTS(auts)
+	return sqnMS, err
+}
+
+// ParseAUTS recovers SQN_MS from AUTS with AK* and returns it with MAC-S in AUTS,
+// after validating MAC-S by recomputing it with f1* and AMF=0x0000 (6.3.3, TS 33.102).
+// m.MACS is not modified.
+//
+// RAND should be the one of the challenge the UE rejected; see VerifyAUTS.
+func (m *Milenage) ParseAUTS(auts []byte) (sqnMS, macS []byte, err error) {
+	if len(auts) != 14 {
+		return nil, nil, fmt.Errorf("length of AUTS should be %d, got: %d", 14, len(auts))
+	}
+
+	aks, err := m.ResyncAK()
+	if err != nil {
+		return nil, nil, err
+	}
+	sqnMS = xor(auts[0:6], aks)
+	macS = append([]byte{}, auts[6:14]...)
+
+	expected, err := m.resyncMAC(sqnMS)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hmac.Equal(expected, macS) {
+		return nil, nil, fmt.Errorf("%w: MAC-S mismatch: expected %x, got %x", ErrMACMismatch, expected, macS)
+	}
+
+	return sqnMS, macS, nil
+}
+
 // computeOPc computes OPc from K and OP inside m.
 func (m *Milenage) computeOPc() error {
-	m.OPc = make([]byte, 16)
+	if m.Crypto == nil {
+		if err := validateK(m.K); err != nil {
+			return err
+		}
+	}
+	if len(m.OP) != 16 {
+		return fmt.Errorf("%w: length of OP should be %d, got: %d", ErrInvalidKeyLength, 16, len(m.OP))
+	}
 
-	block, err := aes.NewCipher(m.K)
+	cipherText, err := m.encrypt(m.OP)
 	if err != nil {
 		return err
 	}
-	cipherText := make([]byte, len(m.OP))
-	block.Encrypt(cipherText, m.OP)
 
-	bytes := xor(cipherText, m.OP)
-	for i, b := range bytes {
-		if i > len(m.OPc) {
-			break
-		}
-		m.OPc[i] = b
+	m.OPc = xor(cipherText, m.OP)
+	return nil
+}
+
+// validateK returns an error unless k is 128 bits (or 256 bits; see K in Milenage).
+func validateK(k []byte) error {
+	if len(k) != 16 && len(k) != 32 {
+		return fmt.Errorf("%w: length of K should be %d or %d, got: %d", ErrInvalidKeyLength, 16, 32, len(k))
 	}
 	return nil
 }
@@ -418,15 +859,40 @@ func xor(b1, b2 []byte) []byte {
 	return out
 }
 
-func encrypt(key, plain []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// isDigits reports whether s consists only of decimal digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func concat(bs ...[]byte) []byte {
+	var out []byte
+	for _, b := range bs {
+		out = append(out, b...)
+	}
+	return out
+}
+
+// temp returns TEMP = E[RAND xor OPc]K, reusing the one last computed if K, OPc
+// and RAND are unchanged. It's not cached with Crypto, which may not be keyed with K.
+func (m *Milenage) temp() ([]byte, error) {
+	input := concat(m.K, m.OPc, m.RAND)
+	if m.Crypto == nil && m.tempValue != nil && bytes.Equal(m.tempInput, input) {
+		return m.tempValue, nil
+	}
+
+	temp, err := m.encrypt(xor(m.RAND, m.OPc))
 	if err != nil {
 		return nil, err
 	}
+	m.traceValue("TEMP", temp)
 
-	encrypted := make([]byte, len(plain))
-	block.Encrypt(encrypted, plain)
-	return encrypted, nil
+	m.tempValue, m.tempInput = temp, input
+	return temp, nil
 }
 
 func (m *Milenage) f1base(sqn, amf []byte) ([]byte, error) {
@@ -440,15 +906,11 @@ func (m *Milenage) f1base(sqn, amf []byte) ([]byte, error) {
 		}
 	}
 
-	rijndaelInput := make([]byte, 16)
-	for i := 0; i < 16; i++ {
-		rijndaelInput[i] = m.RAND[i] ^ m.OPc[i]
-	}
-
-	temp, err := encrypt(m.K, rijndaelInput)
+	temp, err := m.temp()
 	if err != nil {
 		return nil, err
 	}
+	rijndaelInput := make([]byte, 16)
 
 	in1 := make([]byte, 16)
 	for i := 0; i < 6; i++ {
@@ -471,26 +933,31 @@ func (m *Milenage) f1base(sqn, amf []byte) ([]byte, error) {
 		rijndaelInput[i] ^= temp[i]
 	}
 
-	out, err := encrypt(m.K, rijndaelInput)
+	out, err := m.encrypt(rijndaelInput)
 	if err != nil {
 		return nil, err
 	}
 
-	return xor(out, m.OPc), nil
+	out1 := xor(out, m.OPc)
+	m.traceValue("OUT1", out1)
+	return out1, nil
 }
 
 func (m *Milenage) validateLength() error {
-	if len(m.K) != 16 {
-		return fmt.Errorf("length of K should be %d, got: %d", 16, len(m.K))
+	// K is not needed (and may not be available) if the cipher is delegated.
+	if m.Crypto == nil {
+		if err := validateK(m.K); err != nil {
+			return err
+		}
 	}
 	if m.OP != nil && len(m.OP) != 16 {
-		return fmt.Errorf("length of OP should be %d, got: %d", 16, len(m.OP))
+		return fmt.Errorf("%w: length of OP should be %d, got: %d", ErrInvalidKeyLength, 16, len(m.OP))
 	}
 	if m.OPc != nil && len(m.OPc) != 16 {
-		return fmt.Errorf("length of OPc should be %d, got: %d", 16, len(m.OPc))
+		return fmt.Errorf("%w: length of OPc should be %d, got: %d", ErrInvalidKeyLength, 16, len(m.OPc))
 	}
 	if len(m.RAND) != 16 {
-		return fmt.Errorf("length of RAND should be %d, got: %d", 16, len(m.RAND))
+		return fmt.Errorf("%w: length of RAND should be %d, got: %d", ErrInvalidRANDLength, 16, len(m.RAND))
 	}
 	if len(m.SQN) != 6 {
 		return fmt.Errorf("length of SQN should be %d, got: %d", 6, len(m.SQN))
@@ -523,23 +990,82 @@ func (m *Milenage) validateLength() error {
 	return nil
 }
 
+// SanityCheck returns a list of warnings for suspicious values in Milenage,
+// which usually indicate a provisioning mistake rather than a real output.
+//
+// Note that this should be called after the computation is done.
+func (m *Milenage) SanityCheck() []string {
+	var warnings []string
+
+	if len(m.CK) != 0 && bytes.Equal(m.CK, m.IK) {
+		warnings = append(warnings, "CK is equal to IK")
+	}
+	if isZero(m.AK) {
+		warnings = append(warnings, "AK is all zero")
+	}
+	if isZero(m.RES) {
+		warnings = append(warnings, "RES is all zero")
+	}
+	if len(m.K) != 0 && bytes.Equal(m.OPc, m.K) {
+		warnings = append(warnings, "OPc is equal to K")
+	}
+	if len(m.K) != 0 && bytes.Equal(m.OP, m.K) {
+		warnings = append(warnings, "OP is equal to K")
+	}
+
+	return warnings
+}
+
+// isZero reports whether b is non-empty and all zero.
+func isZero(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SetTrace sets the function to be called with the intermediate values
+// (TEMP, OUT1 to OUT5 in TS 35.206 and the input string S to the RES* derivation)
+// during the computation, so that each step can be cross-checked with the specifications.
+//
+// Passing nil disables tracing.
+func (m *Milenage) SetTrace(fn func(name string, value []byte)) {
+	m.trace = fn
+}
+
+func (m *Milenage) traceValue(name string, value []byte) {
+	if m.trace != nil {
+		m.trace(name, value)
+	}
+}
+
 // DisplayMilenage prints all fields of a Milenage struct
 func (m *Milenage) DisplayMilenage() {
-	fmt.Println("Milenage Struct Contents:")
-	fmt.Println("K       :", hex.EncodeToString(m.K))
-	fmt.Println("OP      :", hex.EncodeToString(m.OP))
-	fmt.Println("OPc     :", hex.EncodeToString(m.OPc))
-	fmt.Println("RAND    :", hex.EncodeToString(m.RAND))
-	fmt.Println("SQN     :", hex.EncodeToString(m.SQN))
-	fmt.Println("AMF     :", hex.EncodeToString(m.AMF))
-	fmt.Println("MACA    :", hex.EncodeToString(m.MACA))
-	fmt.Println("MACS    :", hex.EncodeToString(m.MACS))
-	fmt.Println("RES     :", hex.EncodeToString(m.RES))
-	fmt.Println("CK      :", hex.EncodeToString(m.CK))
-	fmt.Println("IK      :", hex.EncodeToString(m.IK))
-	fmt.Println("AK      :", hex.EncodeToString(m.AK))
-	fmt.Println("AKS     :", hex.EncodeToString(m.AKS))
-	fmt.Println("RESStar :", hex.EncodeToString(m.RESStar))
+	m.Dump(os.Stdout)
+}
+
+// Dump writes all fields of a Milenage struct to w in the same format as DisplayMilenage.
+func (m *Milenage) Dump(w io.Writer) {
+	fmt.Fprintln(w, "Milenage Struct Contents:")
+	fmt.Fprintln(w, "K       :", hex.EncodeToString(m.K))
+	fmt.Fprintln(w, "OP      :", hex.EncodeToString(m.OP))
+	fmt.Fprintln(w, "OPc     :", hex.EncodeToString(m.OPc))
+	fmt.Fprintln(w, "RAND    :", hex.EncodeToString(m.RAND))
+	fmt.Fprintln(w, "SQN     :", hex.EncodeToString(m.SQN))
+	fmt.Fprintln(w, "AMF     :", hex.EncodeToString(m.AMF))
+	fmt.Fprintln(w, "MACA    :", hex.EncodeToString(m.MACA))
+	fmt.Fprintln(w, "MACS    :", hex.EncodeToString(m.MACS))
+	fmt.Fprintln(w, "RES     :", hex.EncodeToString(m.RES))
+	fmt.Fprintln(w, "CK      :", hex.EncodeToString(m.CK))
+	fmt.Fprintln(w, "IK      :", hex.EncodeToString(m.IK))
+	fmt.Fprintln(w, "AK      :", hex.EncodeToString(m.AK))
+	fmt.Fprintln(w, "AKS     :", hex.EncodeToString(m.AKS))
+	fmt.Fprintln(w, "RESStar :", hex.EncodeToString(m.RESStar))
 }
 
 func Xor(b1, b2 []byte) []byte {
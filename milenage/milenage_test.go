@@ -0,0 +1,669 @@
+package milenage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// mustHex decodes s in hex, failing the test if it's malformed.
+func mustHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		tb.Fatalf("hex.DecodeString(%q) failed: %v", s, err)
+	}
+	return b
+}
+
+// ts35208 is the test sets 1 to 19 in TS 35.208 4.3.
+var ts35208 = []struct {
+	k, rand, sqn, amf, op, opc         string
+	f1, f1Star, f2, f3, f4, f5, f5Star string
+}{
+	{
+		k: "465b5ce8b199b49faa5f0a2ee238a6bc", rand: "23553cbe9637a89d218ae64dae47bf35",
+		sqn: "ff9bb4d0b607", amf: "b9b9",
+		op: "cdc202d5123e20f62b6d676ac72cb318", opc: "cd63cb71954a9f4e48a5994e37a02baf",
+		f1: "4a9ffac354dfafb3", f1Star: "01cfaf9ec4e871e9", f2: "a54211d5e3ba50bf",
+		f3: "b40ba9a3c58b2a05bbf0d987b21bf8cb", f4: "f769bcd751044604127672711c6d3441",
+		f5: "aa689c648370", f5Star: "451e8beca43b",
+	},
+	{
+		k: "0396eb317b6d1c36f19c1c84cd6ffd16", rand: "c00d603103dcee52c4478119494202e8",
+		sqn: "fd8eef40df7d", amf: "af17",
+		op: "ff53bade17df5d4e793073ce9d7579fa", opc: "53c15671c60a4b731c55b4a441c0bde2",
+		f1: "5df5b31807e258b0", f1Star: "a8c016e51ef4a343", f2: "d3a628ed988620f0",
+		f3: "58c433ff7a7082acd424220f2b67c556", f4: "21a8c1f929702adb3e738488b9f5c5da",
+		f5: "c47783995f72", f5Star: "30f1197061c1",
+	},
+	{
+		k: "fec86ba6eb707ed08905757b1bb44b8f", rand: "9f7c8d021accf4db213ccff0c7f71a6a",
+		sqn: "9d0277595ffc", amf: "725c",
+		op: "dbc59adcb6f9a0ef735477b7fadf8374", opc: "1006020f0a478bf6b699f15c062e42b3",
+		f1: "9cabc3e99baf7281", f1Star: "95814ba2b3044324", f2: "8011c48c0c214ed2",
+		f3: "5dbdbb2954e8f3cde665b046179a5098", f4: "59a92d3b476a0443487055cf88b2307b",
+		f5: "33484dc2136b", f5Star: "deacdd848cc6",
+	},
+	{
+		k: "9e5944aea94b81165c82fbf9f32db751", rand: "ce83dbc54ac0274a157c17f80d017bd6",
+		sqn: "0b604a81eca8", amf: "9e09",
+		op: "223014c5806694c007ca1eeef57f004f", opc: "a64a507ae1a2a98bb88eb4210135dc87",
+		f1: "74a58220cba84c49", f1Star: "ac2cc74a96871837", f2: "f365cd683cd92e96",
+		f3: "e203edb3971574f5a94b0d61b816345d", f4: "0c4524adeac041c4dd830d20854fc46b",
+		f5: "f0b9c08ad02e", f5Star: "6085a86c6f63",
+	},
+	{
+		k: "4ab1deb05ca6ceb051fc98e77d026a84", rand: "74b0cd6031a1c8339b2b6ce2b8c4a186",
+		sqn: "e880a1b580b6", amf: "9f07",
+		op: "2d16c5cd1fdf6b22383584e3bef2a8d8", opc: "dcf07cbd51855290b92a07a9891e523e",
+		f1: "49e785dd12626ef2", f1Star: "9e85790336bb3fa2", f2: "5860fc1bce351e7e",
+		f3: "7657766b373d1c2138f307e3de9242f9", f4: "1c42e960d89b8fa99f2744e0708ccb53",
+		f5: "31e11a609118", f5Star: "fe2555e54aa9",
+	},
+	{
+		k: "6c38a116ac280c454f59332ee35c8c4f", rand: "ee6466bc96202c5a557abbeff8babf63",
+		sqn: "414b98222181", amf: "4464",
+		op: "1ba00a1a7c6700ac8c3ff3e96ad08725", opc: "3803ef5363b947c6aaa225e58fae3934",
+		f1: "078adfb488241a57", f1Star: "80246b8d0186bcf1", f2: "16c8233f05a0ac28",
+		f3: "3f8c7587fe8e4b233af676aede30ba3b", f4: "a7466cc1e6b2a1337d49d3b66e95d7b4",
+		f5: "45b0f69ab06c", f5Star: "1f53cd2b1113",
+	},
+	{
+		k: "2d609d4db0ac5bf0d2c0de267014de0d", rand: "194aa756013896b74b4a2a3b0af4539e",
+		sqn: "6bf69438c2e4", amf: "5f67",
+		op: "460a48385427aa39264aac8efc9e73e8", opc: "c35a0ab0bcbfc9252caff15f24efbde0",
+		f1: "bd07d3003b9e5cc3", f1Star: "bcb6c2fcad152250", f2: "8c25a16cd918a1df",
+		f3: "4cd0846020f8fa0731dd47cbdc6be411", f4: "88ab80a415f15c73711254a1d388f696",
+		f5: "7e6455f34cf3", f5Star: "dc6dd01e8f15",
+	},
+	{
+		k: "a530a7fe428fad1082c45eddfce13884", rand: "3a4c2b3245c50eb5c71d08639395764d",
+		sqn: "f63f5d768784", amf: "b90e",
+		op: "511c6c4e83e38c89b1c5d8dde62426fa", opc: "27953e49bc8af6dcc6e730eb80286be3",
+		f1: "53761fbd679b0bad", f1Star: "21adfd334a10e7ce", f2: "a63241e1ffc3e5ab",
+		f3: "10f05bab75a99a5fbb98a9c287679c3b", f4: "f9ec0865eb32f22369cade40c59c3a44",
+		f5: "88196c47986f", f5Star: "c987a3d23115",
+	},
+	{
+		k: "d9151cf04896e25830bf2e08267b8360", rand: "f761e5e93d603feb730e27556cb8a2ca",
+		sqn: "47ee0199820a", amf: "9113",
+		op: "75fc2233a44294ee8e6de25c4353d26b", opc: "c4c93effe8a08138c203d4c27ce4e3d9",
+		f1: "66cc4be44862af1f", f1Star: "7a4b8d7a8753f246", f2: "4a90b2171ac83a76",
+		f3: "71236b7129f9b22ab77ea7a54c96da22", f4: "90527ebaa5588968db41727325a04d9e",
+		f5: "82a0f5287a71", f5Star: "527dbf41f35f",
+	},
+	{
+		k: "a0e2971b6822e8d354a18cc235624ecb", rand: "08eff828b13fdb562722c65c7f30a9b2",
+		sqn: "db5c066481e0", amf: "716b",
+		op: "323792faca21fb4d5d6f13c145a9d2c1", opc: "82a26f22bba9e9488f949a10d98e9cc4",
+		f1: "9485fe24621cb9f6", f1Star: "bce325ce03e2e9b9", f2: "4bc2212d8624910a",
+		f3: "08cef6d004ec61471a3c3cda048137fa", f4: "ed0318ca5deb9206272f6e8fa64ba411",
+		f5: "a2f858aa9e5d", f5Star: "74e76fbbec38",
+	},
+	{
+		k: "0da6f7ba86d5eac8a19cf563ac58642d", rand: "679ac4dbacd7d233ff9d6806f4149ce3",
+		sqn: "6e2331d692ad", amf: "224a",
+		op: "4b9a26fa459e3acbff36f4015de3bdc1", opc: "0db1071f8767562ca43a0a64c41e8d08",
+		f1: "2831d7ae9088e492", f1Star: "9b2e16951135d523", f2: "6fc30fee6d123523",
+		f3: "69b1cae7c7429d975e245cacb05a517c", f4: "74f24e8c26df58e1b38d7dcd4f1b7fbd",
+		f5: "4c539a26e1fa", f5Star: "07861e126928",
+	},
+	{
+		k: "77b45843c88e58c10d202684515ed430", rand: "4c47eb3076dc55fe5106cb2034b8cd78",
+		sqn: "88ad1d96f6b3", amf: "d7a7",
+		op: "bf3286c7a51409ce95724d503bfe6e70", opc: "d483afae562409a326b5bb0b20c4d762",
+		f1: "fa3de5ca17cbbb72", f1Star: "333aff9ddd283a13", f2: "aefa357beac2a87a",
+		f3: "908c43f0569cb8f74bc971e706c36c5f", f4: "c251df0d888dd9329bcf46655b226e40",
+		f5: "30ff25cdadf6", f5Star: "e84ed0d4677e",
+	},
+	{
+		k: "729b17729270dd87ccdf1bfe29b4e9bb", rand: "311c4c929744d675b720f3b7e9b1cbd0",
+		sqn: "c85c4cf65916", amf: "2ef4",
+		op: "d04c9c35bd2262fa810d2924d036fd13", opc: "228c2f2f06ac3268a9e616ee16db4ba1",
+		f1: "f80e747491393c57", f1Star: "3baee88f3eecd066", f2: "98dbbd099b3b408d",
+		f3: "44c0f23c5493cfd241e48f197e1d1012", f4: "0c9fb81613884c2535dd0eabf3b440d8",
+		f5: "5380d158cfe3", f5Star: "87ac3b559fb6",
+	},
+	{
+		k: "d32dd23e89dc662354ca12eb79dd32fa", rand: "cf7d0ab1d94306950bf12018fbd46887",
+		sqn: "484107e56a43", amf: "02e3",
+		op: "fe75905b9da47d356236d0314e09c32e", opc: "d22a4b4180a5325708a5ff70d9f67ec7",
+		f1: "e9ae21d233821a92", f1Star: "07df3769be40ce82", f2: "af4a411e1139f2c2",
+		f3: "5af86b80edb70df5292cc1121cbad50c", f4: "7f4d6ae7440e18789a8b75ad3f42f03a",
+		f5: "217af49272ad", f5Star: "900e101c677e",
+	},
+	{
+		k: "af7c65e1927221de591187a2c5987a53", rand: "1f0f8578464fd59b64bed2d09436b57a",
+		sqn: "3d627b01418d", amf: "4f6b",
+		op: "0c7acb8d95b7d4a31c5aca6d26345a88", opc: "a4cf5c8155c08a7eff418e5443b98e55",
+		f1: "90bae6bb49705dd4", f1Star: "bbcfb06001023da3", f2: "7bffa5c2f41fbc05",
+		f3: "3f8c3f3ccf7625bf77fc94bcfd22fd26", f4: "abcbae8fd46115e9961a55d0da5f2078",
+		f5: "837fd7b74419", f5Star: "56e97a6090b1",
+	},
+	{
+		k: "5bd7ecd3d3127a41d12539bed4e7cf71", rand: "59b75f14251c75031d0bcbac1c2c04c7",
+		sqn: "a298ae8929dc", amf: "d56c",
+		op: "f967f76038b920a9cd25e10c08b49924", opc: "76089d3c0ff3efdc6e36721d4fceb747",
+		f1: "6038ef6786d863d8", f1Star: "1fa251a397699775", f2: "7e3f44c7591f6f45",
+		f3: "d42b2d615e49a03ac275a5aef97af892", f4: "0b3f8d024fe6bfafaa982b8f82e319c2",
+		f5: "5be11495525d", f5Star: "4d6a34a1e4eb",
+	},
+	{
+		k: "6cd1c6ceb1e01e14f1b82316a90b7f3d", rand: "f69b78f300a0568bce9f0cb93c4be4c9",
+		sqn: "b4fce5feb059", amf: "e4bb",
+		op: "078bfca9564659ecd8851e84e6c59b48", opc: "a219dc37f1dc7d66738b5843c799f206",
+		f1: "69a90869c268cb7b", f1Star: "2e0fdcf9fd1cfa6a", f2: "70f6bdb9ad21525f",
+		f3: "6edaf99e5bd9f85d5f36d91c1272fb4b", f4: "d61c853c280dd9c46f297baec386de17",
+		f5: "1c408a858b3e", f5Star: "aa4ae52daa30",
+	},
+	{
+		k: "b73a90cbcf3afb622dba83c58a8415df", rand: "b120f1c1a0102a2f507dd543de68281f",
+		sqn: "f1e8a523a36d", amf: "471b",
+		op: "b672047e003bb952dca6cb8af0e5b779", opc: "df0c67868fa25f748b7044c6e7c245b8",
+		f1: "ebd70341bcd415b0", f1Star: "12359f5d82220c14", f2: "479dd25c20792d63",
+		f3: "66195dbed0313274c5ca7766615fa25e", f4: "66bec707eb2afc476d7408a8f2927b36",
+		f5: "aefdaa5ddd99", f5Star: "12ec2b87fbb1",
+	},
+	{
+		k: "5122250214c33e723a5dd523fc145fc0", rand: "81e92b6c0ee0e12ebceba8d92a99dfa5",
+		sqn: "16f3b3f70fc2", amf: "c3ab",
+		op: "c9e8763286b5b9ffbdf56e1297d0887b", opc: "981d464c7c52eb6e5036234984ad0bcf",
+		f1: "2a5c23d15ee351d5", f1Star: "62dae3853f3af9d2", f2: "28d7b0f2a2ec3de5",
+		f3: "5349fbe098649f948f5d2e973a81c00f", f4: "9744871ad32bf9bbd1dd5ce54e3e2e5a",
+		f5: "ada15aeb7bb8", f5Star: "d461bc15475d",
+	},
+}
+
+func TestTS35208(t *testing.T) {
+	for i, tt := range ts35208 {
+		t.Run(fmt.Sprintf("Set %d", i+1), func(t *testing.T) {
+			opc, err := ComputeOPc(mustHex(t, tt.k), mustHex(t, tt.op))
+			if err != nil {
+				t.Fatalf("ComputeOPc() failed: %v", err)
+			}
+			if want := mustHex(t, tt.opc); !bytes.Equal(opc, want) {
+				t.Errorf("OPc = %x, want %x", opc, want)
+			}
+
+			m := NewWithOPc(mustHex(t, tt.k), mustHex(t, tt.opc), mustHex(t, tt.rand), 0, 0)
+			m.SQN = mustHex(t, tt.sqn)
+			m.AMF = mustHex(t, tt.amf)
+
+			macA, err := m.F1()
+			if err != nil {
+				t.Fatalf("F1() failed: %v", err)
+			}
+			macS, err := m.F1Star(m.SQN, m.AMF)
+			if err != nil {
+				t.Fatalf("F1Star() failed: %v", err)
+			}
+			res, ck, ik, ak, err := m.F2345()
+			if err != nil {
+				t.Fatalf("F2345() failed: %v", err)
+			}
+			aks, err := m.F5Star()
+			if err != nil {
+				t.Fatalf("F5Star() failed: %v", err)
+			}
+
+			for _, v := range []struct {
+				name      string
+				got, want []byte
+			}{
+				{"f1", macA, mustHex(t, tt.f1)},
+				{"f1*", macS, mustHex(t, tt.f1Star)},
+				{"f2", res, mustHex(t, tt.f2)},
+				{"f3", ck, mustHex(t, tt.f3)},
+				{"f4", ik, mustHex(t, tt.f4)},
+				{"f5", ak, mustHex(t, tt.f5)},
+				{"f5*", aks, mustHex(t, tt.f5Star)},
+			} {
+				if !bytes.Equal(v.got, v.want) {
+					t.Errorf("%s = %x, want %x", v.name, v.got, v.want)
+				}
+			}
+		})
+	}
+}
+
+// newTestMilenage returns a Milenage with the default values of the command.
+func newTestMilenage(tb testing.TB) *Milenage {
+	tb.Helper()
+	return NewWithOPc(
+		mustHex(tb, "00112233445566778899aabbccddeeff"),
+		mustHex(tb, "62e75b8d6fa5bf46ec87a9276f9df54d"),
+		mustHex(tb, "00112233445566778899aabbccddeeff"),
+		1, 0x8000)
+}
+
+func BenchmarkF2345(b *testing.B) {
+	m := newTestMilenage(b)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// vary RAND so that every call sees a new input
+		m.RAND[0] = byte(i)
+		if _, _, _, _, err := m.F2345(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkF1Base(b *testing.B) {
+	m := newTestMilenage(b)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.RAND[0] = byte(i)
+		if _, err := m.f1base(m.SQN, m.AMF); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyAUTS(t *testing.T) {
+	ue := newTestMilenage(t)
+	auts, err := ue.GenerateAUTS()
+	if err != nil {
+		t.Fatalf("GenerateAUTS() failed: %v", err)
+	}
+
+	hn := newTestMilenage(t)
+	hn.SQN = mustHex(t, "000000000040")
+	sqnMS, err := hn.VerifyAUTS(ue.RAND, auts)
+	if err != nil {
+		t.Fatalf("VerifyAUTS() failed: %v", err)
+	}
+	if !bytes.Equal(sqnMS, ue.SQN) {
+		t.Errorf("SQN_MS = %x, want %x", sqnMS, ue.SQN)
+	}
+}
+
+func TestVerifyAUTSWrongRAND(t *testing.T) {
+	ue := newTestMilenage(t)
+	auts, err := ue.GenerateAUTS()
+	if err != nil {
+		t.Fatalf("GenerateAUTS() failed: %v", err)
+	}
+
+	// the network has moved on to another challenge since
+	hn := newTestMilenage(t)
+	hn.RAND = mustHex(t, "ffeeddccbbaa99887766554433221100")
+	if _, err := hn.VerifyAUTS(ue.RAND, auts); !errors.Is(err, ErrMACMismatch) {
+		t.Errorf("VerifyAUTS() with another RAND: err = %v, want %v", err, ErrMACMismatch)
+	}
+}
+
+func TestComputeRESStarInput(t *testing.T) {
+	const snn = "5G:mnc001.mcc001.3gppnetwork.org"
+
+	m := newTestMilenage(t)
+	if _, _, _, _, err := m.F2345(); err != nil {
+		t.Fatalf("F2345() failed: %v", err)
+	}
+
+	var s []byte
+	m.SetTrace(func(name string, value []byte) {
+		if name == "S(RES*)" {
+			s = append([]byte(nil), value...)
+		}
+	})
+	resStar, err := m.ComputeRESStarSNN(snn)
+	if err != nil {
+		t.Fatalf("ComputeRESStarSNN() failed: %v", err)
+	}
+
+	// FC || P0 || L0 || P1 || L1 || P2 || L2 (A.4, TS 33.501)
+	if len(s) != 63 {
+		t.Fatalf("length of S = %d, want 63", len(s))
+	}
+	fields := []struct {
+		name       string
+		start, end int
+		want       []byte
+	}{
+		{"FC", 0, 1, []byte{0x6b}},
+		{"P0 (SNN)", 1, 33, []byte(snn)},
+		{"L0", 33, 35, []byte{0x00, 0x20}},
+		{"P1 (RAND)", 35, 51, m.RAND},
+		{"L1", 51, 53, []byte{0x00, 0x10}},
+		{"P2 (RES)", 53, 61, m.RES},
+		{"L2", 61, 63, []byte{0x00, 0x08}},
+	}
+	for _, f := range fields {
+		if got := s[f.start:f.end]; !bytes.Equal(got, f.want) {
+			t.Errorf("%s = %x, want %x", f.name, got, f.want)
+		}
+	}
+
+	if want := KDFInput(0x6b, []byte(snn), m.RAND, m.RES); !bytes.Equal(s, want) {
+		t.Errorf("S = %x, want KDFInput() = %x", s, want)
+	}
+
+	mac := hmac.New(sha256.New, append(append([]byte(nil), m.CK...), m.IK...))
+	mac.Write(s)
+	if want := mac.Sum(nil)[16:]; !bytes.Equal(resStar, want) {
+		t.Errorf("RES* = %x, want %x", resStar, want)
+	}
+}
+
+func TestF5Star(t *testing.T) {
+	// Test Set 1, TS 35.208
+	m := NewWithOPc(
+		mustHex(t, "465b5ce8b199b49faa5f0a2ee238a6bc"),
+		mustHex(t, "cd63cb71954a9f4e48a5994e37a02baf"),
+		mustHex(t, "23553cbe9637a89d218ae64dae47bf35"),
+		0xff9bb4d0b607, 0xb9b9)
+
+	aks, err := m.F5Star()
+	if err != nil {
+		t.Fatalf("F5Star() failed: %v", err)
+	}
+	if want := mustHex(t, "451e8beca43b"); !bytes.Equal(aks, want) {
+		t.Errorf("AK* = %x, want %x", aks, want)
+	}
+	if !bytes.Equal(m.AKS, aks) {
+		t.Errorf("m.AKS = %x, want %x", m.AKS, aks)
+	}
+}
+
+func BenchmarkComputeOPc(b *testing.B) {
+	k := mustHex(b, "465b5ce8b199b49faa5f0a2ee238a6bc")
+	op := mustHex(b, "cdc202d5123e20f62b6d676ac72cb318")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// a distinct K for each subscriber, as in bulk provisioning
+		k[0] = byte(i)
+		if _, err := ComputeOPc(k, op); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGenerateAUTSKeepsAMF(t *testing.T) {
+	m := newTestMilenage(t)
+	macA, err := m.F1()
+	if err != nil {
+		t.Fatalf("F1() failed: %v", err)
+	}
+	amf := append([]byte(nil), m.AMF...)
+
+	if _, err := m.GenerateAUTS(); err != nil {
+		t.Fatalf("GenerateAUTS() failed: %v", err)
+	}
+	if !bytes.Equal(m.AMF, amf) {
+		t.Errorf("AMF = %x after GenerateAUTS(), want %x", m.AMF, amf)
+	}
+	if !bytes.Equal(m.MACA, macA) {
+		t.Errorf("MAC-A = %x after GenerateAUTS(), want %x", m.MACA, macA)
+	}
+
+	// F1 still uses the original AMF, not 0x0000 used for MAC-S
+	again, err := m.F1()
+	if err != nil {
+		t.Fatalf("F1() failed: %v", err)
+	}
+	if !bytes.Equal(again, macA) {
+		t.Errorf("F1() after GenerateAUTS() = %x, want %x", again, macA)
+	}
+}
+
+func TestComputeRESStarInvalidPLMN(t *testing.T) {
+	tests := []struct{ mcc, mnc string }{
+		{"abc", "de"},
+		{"001", "de"},
+		{"abc", "01"},
+		{"01", "01"},
+		{"001", "1"},
+		{"001", "0001"},
+	}
+	for _, tt := range tests {
+		m := newTestMilenage(t)
+		if _, _, _, _, err := m.F2345(); err != nil {
+			t.Fatalf("F2345() failed: %v", err)
+		}
+		if _, err := m.ComputeRESStar(tt.mcc, tt.mnc); !errors.Is(err, ErrInvalidSNN) {
+			t.Errorf("ComputeRESStar(%q, %q): err = %v, want %v", tt.mcc, tt.mnc, err, ErrInvalidSNN)
+		}
+	}
+}
+
+func TestAUTNAndAUTSAnonymityKeys(t *testing.T) {
+	tests := []struct {
+		rand string
+		sqn  uint64
+	}{
+		{"00112233445566778899aabbccddeeff", 1},
+		{"23553cbe9637a89d218ae64dae47bf35", 0xff9bb4d0b607},
+		{"ffeeddccbbaa99887766554433221100", 0x000000000020},
+	}
+	for _, tt := range tests {
+		m := newTestMilenage(t)
+		m.RAND = mustHex(t, tt.rand)
+		m.SQN = mustHex(t, fmt.Sprintf("%012x", tt.sqn))
+
+		if _, err := m.F1(); err != nil {
+			t.Fatalf("F1() failed: %v", err)
+		}
+		_, _, _, ak, err := m.F2345()
+		if err != nil {
+			t.Fatalf("F2345() failed: %v", err)
+		}
+		aks, err := m.Clone().F5Star()
+		if err != nil {
+			t.Fatalf("F5Star() failed: %v", err)
+		}
+		if bytes.Equal(ak, aks) {
+			t.Fatalf("AK = AK* = %x for RAND %s", ak, tt.rand)
+		}
+
+		autn, err := m.GenerateAUTN()
+		if err != nil {
+			t.Fatalf("GenerateAUTN() failed: %v", err)
+		}
+		if want := xor(m.SQN, ak); !bytes.Equal(autn[:6], want) {
+			t.Errorf("SQN xor AK in AUTN = %x, want %x", autn[:6], want)
+		}
+
+		auts, err := m.GenerateAUTS()
+		if err != nil {
+			t.Fatalf("GenerateAUTS() failed: %v", err)
+		}
+		if want := xor(m.SQN, aks); !bytes.Equal(auts[:6], want) {
+			t.Errorf("SQN_MS xor AK* in AUTS = %x, want %x", auts[:6], want)
+		}
+
+		// AK* doesn't bleed into AUTN generated afterwards
+		if !bytes.Equal(m.AK, ak) {
+			t.Errorf("AK = %x after GenerateAUTS(), want %x", m.AK, ak)
+		}
+		again, err := m.GenerateAUTN()
+		if err != nil {
+			t.Fatalf("GenerateAUTN() failed: %v", err)
+		}
+		if !bytes.Equal(again, autn) {
+			t.Errorf("GenerateAUTN() after GenerateAUTS() = %x, want %x", again, autn)
+		}
+	}
+}
+
+func TestAES256K(t *testing.T) {
+	k := mustHex(t, "00112233445566778899aabbccddeeff0123456789abcdeffedcba9876543210")
+	op := mustHex(t, "cdc202d5123e20f62b6d676ac72cb318")
+	rand := mustHex(t, "23553cbe9637a89d218ae64dae47bf35")
+
+	hn := New(k, op, rand, 0x20, 0x8000)
+	if _, err := hn.F1(); err != nil {
+		t.Fatalf("F1() failed: %v", err)
+	}
+	if _, _, _, _, err := hn.F2345(); err != nil {
+		t.Fatalf("F2345() failed: %v", err)
+	}
+	autn, err := hn.GenerateAUTN()
+	if err != nil {
+		t.Fatalf("GenerateAUTN() failed: %v", err)
+	}
+
+	// the UE with the same 256-bit K recovers SQN and verifies MAC-A
+	ue := New(k, op, rand, 0, 0)
+	if ok, err := ue.VerifyAUTN(autn); err != nil || !ok {
+		t.Fatalf("VerifyAUTN() = %v, %v, want true", ok, err)
+	}
+	if !bytes.Equal(ue.SQN, hn.SQN) {
+		t.Errorf("SQN recovered by the UE = %x, want %x", ue.SQN, hn.SQN)
+	}
+
+	// all of K is used, not only the first 128 bits
+	short := New(k[:16], op, rand, 0x20, 0x8000)
+	macA, err := short.F1()
+	if err != nil {
+		t.Fatalf("F1() failed: %v", err)
+	}
+	if bytes.Equal(macA, hn.MACA) {
+		t.Errorf("MAC-A = %x with both 256-bit K and its first 128 bits", macA)
+	}
+}
+
+func TestInvalidKLength(t *testing.T) {
+	k := mustHex(t, "00112233445566778899aabbccddeeff0011223344556677")
+	op := mustHex(t, "cdc202d5123e20f62b6d676ac72cb318")
+
+	if _, err := ComputeOPc(k, op); !errors.Is(err, ErrInvalidKeyLength) {
+		t.Errorf("ComputeOPc() with a 24-byte K: err = %v, want %v", err, ErrInvalidKeyLength)
+	}
+
+	m := New(k, op, mustHex(t, "23553cbe9637a89d218ae64dae47bf35"), 1, 0x8000)
+	if _, err := m.F1(); !errors.Is(err, ErrInvalidKeyLength) {
+		t.Errorf("F1() with a 24-byte K: err = %v, want %v", err, ErrInvalidKeyLength)
+	}
+	if _, _, _, _, err := m.F2345(); !errors.Is(err, ErrInvalidKeyLength) {
+		t.Errorf("F2345() with a 24-byte K: err = %v, want %v", err, ErrInvalidKeyLength)
+	}
+}
+
+func TestEncodeForNAS(t *testing.T) {
+	m := newTestMilenage(t)
+	if _, err := m.F1(); err != nil {
+		t.Fatalf("F1() failed: %v", err)
+	}
+	if _, _, _, _, err := m.F2345(); err != nil {
+		t.Fatalf("F2345() failed: %v", err)
+	}
+	autn, err := m.GenerateAUTN()
+	if err != nil {
+		t.Fatalf("GenerateAUTN() failed: %v", err)
+	}
+	auts, err := m.GenerateAUTS()
+	if err != nil {
+		t.Fatalf("GenerateAUTS() failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		v      []byte
+		encode func([]byte) ([]byte, error)
+		decode func([]byte) ([]byte, error)
+	}{
+		{"AUTN", autn, EncodeAUTNForNAS, DecodeAUTNFromNAS},
+		{"AUTS", auts, EncodeAUTSForNAS, DecodeAUTSFromNAS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.encode(tt.v)
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			got, err := tt.decode(b)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.v) {
+				t.Errorf("decode(encode(%x)) = %x", tt.v, got)
+			}
+
+			// 256 bytes would wrap the length octet around to 0
+			for _, l := range []int{0, len(tt.v) - 1, len(tt.v) + 1, 256} {
+				if b, err := tt.encode(make([]byte, l)); err == nil {
+					t.Errorf("encode() of %d bytes = %x, want an error", l, b)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateVectorSeriesNegativeCount(t *testing.T) {
+	m := newTestMilenage(t)
+	if v, err := m.GenerateVectorSeries(-1); err == nil {
+		t.Errorf("GenerateVectorSeries(-1) = %d vectors, want an error", len(v))
+	}
+	if v, err := m.GenerateVectorSeriesPrefixed([]byte{0x01}, -1); err == nil {
+		t.Errorf("GenerateVectorSeriesPrefixed(-1) = %d vectors, want an error", len(v))
+	}
+}
+
+func TestBatchComputeChecked(t *testing.T) {
+	k := mustHex(t, "00112233445566778899aabbccddeeff")
+	opc := mustHex(t, "62e75b8d6fa5bf46ec87a9276f9df54d")
+	inputs := []Input{
+		{K: k, OPc: opc, RAND: mustHex(t, "00112233445566778899aabbccddeeff"), SQN: 1, AMF: 0x8000},
+		{K: k, OPc: opc, RAND: mustHex(t, "ffeeddccbbaa99887766554433221100"), SQN: 1, AMF: 0x8000},
+		{K: k, OPc: opc, RAND: mustHex(t, "00112233445566778899aabbccddeeff"), SQN: 33, AMF: 0x8000},
+	}
+	if _, err := BatchComputeChecked(inputs, 2); err != nil {
+		t.Fatalf("BatchComputeChecked() failed: %v", err)
+	}
+
+	inputs = append(inputs, inputs[0])
+	if _, err := BatchCompute(inputs, 2); err != nil {
+		t.Fatalf("BatchCompute() with a replay failed: %v", err)
+	}
+	_, err := BatchComputeChecked(inputs, 2)
+	if err == nil || !strings.Contains(err.Error(), "#0 and #3") {
+		t.Errorf("BatchComputeChecked() with a replay: err = %v, want one about #0 and #3", err)
+	}
+}
+
+func TestNewPool(t *testing.T) {
+	set := ts35208[0]
+	k, op := mustHex(t, set.k), mustHex(t, set.op)
+	want := NewWithOPc(k, mustHex(t, set.opc), mustHex(t, set.rand), 0, 0)
+	want.SQN, want.AMF = mustHex(t, set.sqn), mustHex(t, set.amf)
+	wantMACA, err := want.F1()
+	if err != nil {
+		t.Fatalf("F1() failed: %v", err)
+	}
+
+	pool, err := NewPool(k, op, 2)
+	if err != nil {
+		t.Fatalf("NewPool() failed: %v", err)
+	}
+	for i, m := range pool {
+		if m.Crypto != nil {
+			t.Errorf("pool[%d].Crypto = %v, want nil", i, m.Crypto)
+		}
+		if !bytes.Equal(m.OPc, want.OPc) {
+			t.Errorf("pool[%d].OPc = %x, want %x", i, m.OPc, want.OPc)
+		}
+
+		m.RAND, m.SQN, m.AMF = want.RAND, want.SQN, want.AMF
+		macA, err := m.F1()
+		if err != nil {
+			t.Fatalf("F1() failed: %v", err)
+		}
+		if !bytes.Equal(macA, wantMACA) {
+			t.Errorf("pool[%d].F1() = %x, want %x", i, macA, wantMACA)
+		}
+	}
+
+	pool[0].K[0] ^= 0xff
+	if pool[1].K[0] != k[0] {
+		t.Error("instances in the pool share K")
+	}
+}
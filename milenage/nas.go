@@ -0,0 +1,53 @@
+package milenage
+
+import "fmt"
+
+// EncodeAUTNForNAS encodes AUTN in the way it's carried in the value part of
+// the Authentication parameter AUTN IE in the NAS Authentication Request,
+// i.e. the length octet followed by AUTN (9.11.3.15, TS 24.501 and 10.5.3.1.1, TS 24.008).
+func EncodeAUTNForNAS(autn []byte) ([]byte, error) {
+	return encodeLV("AUTN", autn, 16)
+}
+
+// DecodeAUTNFromNAS decodes AUTN encoded by EncodeAUTNForNAS,
+// validating the length octet.
+func DecodeAUTNFromNAS(b []byte) ([]byte, error) {
+	return decodeLV("AUTN", b, 16)
+}
+
+// EncodeAUTSForNAS encodes AUTS in the way it's carried in the value part of
+// the Authentication failure parameter IE in the NAS Authentication Failure,
+// i.e. the length octet followed by AUTS (9.11.3.14, TS 24.501 and 10.5.3.2.2, TS 24.008).
+func EncodeAUTSForNAS(auts []byte) ([]byte, error) {
+	return encodeLV("AUTS", auts, 14)
+}
+
+// DecodeAUTSFromNAS decodes AUTS encoded by EncodeAUTSForNAS,
+// validating the length octet.
+func DecodeAUTSFromNAS(b []byte) ([]byte, error) {
+	return decodeLV("AUTS", b, 14)
+}
+
+func encodeLV(name string, v []byte, l int) ([]byte, error) {
+	if len(v) != l {
+		return nil, fmt.Errorf("length of %s should be %d, got: %d", name, l, len(v))
+	}
+
+	b := make([]byte, 1+l)
+	b[0] = uint8(l)
+	copy(b[1:], v)
+	return b, nil
+}
+
+func decodeLV(name string, b []byte, l int) ([]byte, error) {
+	if len(b) != 1+l {
+		return nil, fmt.Errorf("length of encoded %s should be %d, got: %d", name, 1+l, len(b))
+	}
+	if int(b[0]) != l {
+		return nil, fmt.Errorf("length octet of %s should be %d, got: %d", name, l, b[0])
+	}
+
+	v := make([]byte, l)
+	copy(v, b[1:])
+	return v, nil
+}
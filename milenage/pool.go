@@ -0,0 +1,23 @@
+package milenage
+
+import "fmt"
+
+// NewPool returns size Milenage instances ready to be used for the subscriber with K and OP,
+// with OPc computed only once and the AES cipher with K expanded only once.
+//
+// The instances are cloned from a template whose OPc and cipher cache are filled in,
+// so each of them has its own copies of K, OP and OPc, and only RAND, SQN and AMF
+// need to be set on them before computation. The expanded cipher is shared,
+// which is safe as it's stateless.
+func NewPool(k, op []byte, size int) ([]*Milenage, error) {
+	template := New(k, op, nil, 0, 0)
+	if err := template.computeOPc(); err != nil {
+		return nil, fmt.Errorf("failed to compute OPc: %w", err)
+	}
+
+	pool := make([]*Milenage, size)
+	for i := range pool {
+		pool[i] = template.Clone()
+	}
+	return pool, nil
+}
@@ -0,0 +1,57 @@
+package milenage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// SyncFailureError is returned by Respond if AUTN is authentic but SQN in it is not
+// fresh, in which case the UE sends AUTS back instead of RES* (6.1.3.2.2, TS 33.501).
+type SyncFailureError struct {
+	// SQN is the one received in AUTN, and SQNMS is SQN_MS that AUTS carries.
+	SQN   uint64
+	SQNMS uint64
+	AUTS  []byte
+}
+
+func (e *SyncFailureError) Error() string {
+	return fmt.Sprintf("synchronisation failure: SQN %012x is not fresh, SQN_MS: %012x, AUTS: %x", e.SQN, e.SQNMS, e.AUTS)
+}
+
+// Respond handles an authentication challenge as the UE does (6.1.3.2, TS 33.501): it
+// verifies MAC-A in AUTN with VerifyAUTN, checks the freshness of SQN with sqnMS, and
+// returns RES* computed with ComputeRESStar for the serving network of MCC and MNC.
+//
+// If SQN is not fresh, a *SyncFailureError with AUTS for the resynchronisation is returned.
+// The freshness is not checked if sqnMS is nil. rand is copied into m.
+func (m *Milenage) Respond(rand, autn []byte, mcc, mnc string, sqnMS *SQNScheme) ([]byte, error) {
+	if len(rand) != 16 {
+		return nil, fmt.Errorf("%w: length of RAND should be %d, got: %d", ErrInvalidRANDLength, 16, len(rand))
+	}
+	m.RAND = append([]byte{}, rand...)
+
+	if _, err := m.VerifyAUTN(autn); err != nil {
+		return nil, fmt.Errorf("VerifyAUTN() failed: %w", err)
+	}
+
+	if sqnMS != nil {
+		s := make([]byte, 8)
+		copy(s[2:], m.SQN)
+		sqn := binary.BigEndian.Uint64(s)
+
+		if ok, highest := sqnMS.Accept(sqn); !ok {
+			auts, err := m.ExpectedAUTS(highest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate AUTS: %w", err)
+			}
+			return nil, &SyncFailureError{SQN: sqn, SQNMS: highest, AUTS: auts}
+		}
+	}
+
+	resStar, err := m.ComputeRESStar(mcc, mnc)
+	if err != nil {
+		return nil, fmt.Errorf("ComputeRESStar() failed: %w", err)
+	}
+	m.RESStar = resStar
+	return resStar, nil
+}
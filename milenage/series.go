@@ -0,0 +1,104 @@
+package milenage
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// SQNStep is the increment of SQN between consecutive vectors, i.e. a SEQ increment
+// with the 5-bit IND recommended in TS 33.102 Annex C.3.2.
+const SQNStep = 32
+
+// GenerateRAND sets a new random RAND generated with crypto/rand to m and returns it,
+// as the network does for each authentication.
+func (m *Milenage) GenerateRAND() ([]byte, error) {
+	rand, err := newRAND()
+	if err != nil {
+		return nil, err
+	}
+	m.RAND = rand
+	return rand, nil
+}
+
+// newRAND returns 16 random bytes read in full from crypto/rand.
+func newRAND() ([]byte, error) {
+	rand := make([]byte, 16)
+	if _, err := crand.Read(rand); err != nil {
+		return nil, fmt.Errorf("failed to generate RAND: %w", err)
+	}
+	return rand, nil
+}
+
+// GenerateVectorSeries generates count vectors with random RANDs using K, OP or OPc
+// and AMF in m. SQN starts from the one in m and is incremented by SQNStep for each vector.
+//
+// m itself is not modified. The result is checked with CheckNoReplays.
+func (m *Milenage) GenerateVectorSeries(count int) ([]Vector, error) {
+	return m.generateVectorSeries(nil, count)
+}
+
+// GenerateVectorSeriesPrefixed is GenerateVectorSeries but the RAND of each vector starts
+// with prefix followed by a big-endian counter (0, 1, ...) of up to 4 bytes, so that the
+// RANDs captured in traces can be correlated to the vectors. The rest of RAND is random.
+//
+// prefix should be up to 16 bytes, leaving enough room for the counter to hold count.
+func (m *Milenage) GenerateVectorSeriesPrefixed(prefix []byte, count int) ([]Vector, error) {
+	if len(prefix) > 16 {
+		return nil, fmt.Errorf("length of prefix should be up to %d, got: %d", 16, len(prefix))
+	}
+	return m.generateVectorSeries(prefix, count)
+}
+
+func (m *Milenage) generateVectorSeries(prefix []byte, count int) ([]Vector, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("count should not be negative, got: %d", count)
+	}
+	if len(m.SQN) != 6 {
+		return nil, fmt.Errorf("length of SQN should be %d, got: %d", 6, len(m.SQN))
+	}
+	if len(m.AMF) != 2 {
+		return nil, fmt.Errorf("length of AMF should be %d, got: %d", 2, len(m.AMF))
+	}
+
+	counterLen := min(4, 16-len(prefix))
+	if prefix != nil && count > 1<<(8*counterLen) {
+		return nil, fmt.Errorf("%d-byte counter after the prefix can't hold %d vectors", counterLen, count)
+	}
+
+	s := make([]byte, 8)
+	copy(s[2:], m.SQN)
+	sqn := binary.BigEndian.Uint64(s)
+
+	vectors := make([]Vector, count)
+	for i := range vectors {
+		rand, err := newRAND()
+		if err != nil {
+			return nil, err
+		}
+		if prefix != nil {
+			copy(rand, prefix)
+			c := make([]byte, 4)
+			binary.BigEndian.PutUint32(c, uint32(i))
+			copy(rand[len(prefix):], c[4-counterLen:])
+		}
+
+		v, err := computeVector(Input{
+			K:    m.K,
+			OP:   m.OP,
+			OPc:  m.OPc,
+			RAND: rand,
+			SQN:  (sqn + uint64(i)*SQNStep) & 0xffffffffffff,
+			AMF:  binary.BigEndian.Uint16(m.AMF),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute vector #%d: %w", i, err)
+		}
+		vectors[i] = v
+	}
+
+	if err := CheckNoReplays(vectors); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
@@ -0,0 +1,81 @@
+package milenage
+
+// maxSQN is the largest value of the 48-bit SQN.
+const maxSQN = 1<<48 - 1
+
+// SQNScheme manages SQN split into SEQ || IND as described in TS 33.102 Annex C.3.2,
+// both on the network side (Next) and on the UE side (Accept).
+//
+// It's not safe for concurrent use.
+type SQNScheme struct {
+	// Delta is the maximum amount SEQ may jump ahead of the highest SEQ accepted
+	// so far (Annex C.2.2). No limit is applied if it's 0.
+	Delta uint64
+
+	// indBits is the number of the least significant bits of SQN used as IND.
+	indBits uint
+
+	// seq and ind are of the last SQN generated by Next.
+	seq, ind uint64
+	// seqMS is the highest SEQ accepted for each IND, and highest is the highest
+	// SQN accepted for any IND, i.e. SQN_MS.
+	seqMS   []uint64
+	highest uint64
+}
+
+// NewSQNScheme creates SQNScheme with sqn being the last SQN generated (or accepted),
+// IND of indBits bits and the limit delta of SEQ. TS 33.102 recommends 5 bits for IND
+// and 2^28 for delta.
+func NewSQNScheme(sqn uint64, indBits uint, delta uint64) *SQNScheme {
+	s := &SQNScheme{
+		indBits: indBits,
+		Delta:   delta,
+		seqMS:   make([]uint64, 1<<indBits),
+		highest: sqn & maxSQN,
+	}
+	s.seq, s.ind = s.split(sqn & maxSQN)
+	s.seqMS[s.ind] = s.seq
+	return s
+}
+
+// Next returns the SQN for the next authentication vector, with SEQ incremented by 1
+// and IND moving to the next value cyclically (Annex C.1.2 and C.3.4).
+func (s *SQNScheme) Next() uint64 {
+	s.seq++
+	s.ind = (s.ind + 1) & s.indMask()
+	return s.join(s.seq, s.ind)
+}
+
+// Accept reports whether the UE accepts sqn received in AUTN (Annex C.2.2), i.e. SEQ is
+// greater than the one last accepted with the same IND and is not too far ahead.
+// It returns sqn if it's accepted, or SQN_MS (the highest SQN accepted) to be sent
+// back in AUTS for resynchronisation if not.
+func (s *SQNScheme) Accept(sqn uint64) (bool, uint64) {
+	seq, ind := s.split(sqn & maxSQN)
+	highestSEQ, _ := s.split(s.highest)
+
+	if seq <= s.seqMS[ind] {
+		return false, s.highest
+	}
+	if s.Delta > 0 && seq > highestSEQ && seq-highestSEQ > s.Delta {
+		return false, s.highest
+	}
+
+	s.seqMS[ind] = seq
+	if seq > highestSEQ {
+		s.highest = sqn & maxSQN
+	}
+	return true, sqn & maxSQN
+}
+
+func (s *SQNScheme) indMask() uint64 {
+	return 1<<s.indBits - 1
+}
+
+func (s *SQNScheme) split(sqn uint64) (seq, ind uint64) {
+	return sqn >> s.indBits, sqn & s.indMask()
+}
+
+func (s *SQNScheme) join(seq, ind uint64) uint64 {
+	return (seq<<s.indBits | ind) & maxSQN
+}
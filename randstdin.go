@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"5G_AKA/aka"
+)
+
+// runRANDStream runs the flow with params for each RAND read from r, one in hex per line,
+// and prints a line of the resulting vector to w for each of them. If format is "json",
+// the line is a JSON object written by writeJSON with op instead.
+//
+// SQN is incremented by sqnStep after each line. Empty lines are skipped.
+func runRANDStream(r io.Reader, w io.Writer, params aka.FlowParams, op []byte, sqnStep uint64, format string) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" {
+			continue
+		}
+
+		rand, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+		if err != nil {
+			return fmt.Errorf("invalid RAND at line %d: %w", line, err)
+		}
+		if len(rand) != 16 {
+			return fmt.Errorf("invalid RAND at line %d: length should be %d, got: %d", line, 16, len(rand))
+		}
+		params.RAND = rand
+
+		res, err := aka.RunFlow(params)
+		if err != nil {
+			return fmt.Errorf("RunFlow() failed at line %d: %w", line, err)
+		}
+		if format == "json" {
+			if err := writeJSON(w, params, op, res); err != nil {
+				return fmt.Errorf("failed to write JSON at line %d: %w", line, err)
+			}
+		} else {
+			fmt.Fprintf(w, "rand=%x sqn=%012x autn=%x xresStar=%x kausf=%x kseaf=%x kamf=%x\n",
+				rand, params.SQN, res.AUTN, res.XRESStar, res.KAUSF, res.KSEAF, res.KAMF)
+		}
+
+		params.SQN += sqnStep
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read RAND: %w", err)
+	}
+	return nil
+}
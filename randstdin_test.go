@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"5G_AKA/aka"
+)
+
+func TestRunRANDStreamJSON(t *testing.T) {
+	k, _ := hex.DecodeString("00112233445566778899aabbccddeeff")
+	opc, _ := hex.DecodeString("62e75b8d6fa5bf46ec87a9276f9df54d")
+	params := aka.FlowParams{
+		IMSI: "001010123456789",
+		MCC:  "001",
+		MNC:  "01",
+		K:    k,
+		OPc:  opc,
+		SQN:  1,
+		AMF:  0x8000,
+	}
+	rands := []string{"00112233445566778899aabbccddeeff", "ffeeddccbbaa99887766554433221100"}
+
+	var out bytes.Buffer
+	in := strings.NewReader(rands[0] + "\n\n0x" + rands[1] + "\n")
+	if err := runRANDStream(in, &out, params, nil, 32, "json"); err != nil {
+		t.Fatalf("runRANDStream() failed: %v", err)
+	}
+
+	sc := bufio.NewScanner(&out)
+	var i int
+	for ; sc.Scan(); i++ {
+		var v struct {
+			RAND  string `json:"rand"`
+			SQN   uint64 `json:"sqn"`
+			KAUSF string `json:"kausf"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v: %s", i+1, err, sc.Bytes())
+		}
+		if i >= len(rands) {
+			continue
+		}
+		if v.RAND != rands[i] {
+			t.Errorf("rand in line %d = %s, want %s", i+1, v.RAND, rands[i])
+		}
+		if want := uint64(1 + 32*i); v.SQN != want {
+			t.Errorf("sqn in line %d = %d, want %d", i+1, v.SQN, want)
+		}
+		if len(v.KAUSF) != 64 {
+			t.Errorf("kausf in line %d = %q, want 32 bytes in hex", i+1, v.KAUSF)
+		}
+	}
+	if i != len(rands) {
+		t.Errorf("got %d lines, want %d", i, len(rands))
+	}
+}
@@ -0,0 +1,211 @@
+package suci
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"5G_AKA/aka"
+)
+
+// Lengths of the fields derived by the KDF and of the MAC tag, which are common
+// to Profile A and B (C.3.4.1 and C.3.4.2, TS 33.501).
+const (
+	eciesEncKeyLen = 16
+	eciesICBLen    = 16
+	eciesMACKeyLen = 32
+	eciesMACLen    = 8
+)
+
+// eciesProfile is a set of parameters of an ECIES protection scheme.
+type eciesProfile struct {
+	curve ecdh.Curve
+
+	// publicKeyLen is the length of the ephemeral public key in the scheme output.
+	publicKeyLen int
+	// parsePublicKey decodes a public key in the form used by the profile.
+	parsePublicKey func(b []byte) (*ecdh.PublicKey, error)
+	// encodePublicKey encodes the ephemeral public key into the scheme output.
+	encodePublicKey func(pub *ecdh.PublicKey) []byte
+
+	kdf func(z, sharedInfo []byte, length int) []byte
+}
+
+// profileA is ECIES Profile A (C.3.4.1, TS 33.501) with Curve25519.
+var profileA = eciesProfile{
+	curve:           ecdh.X25519(),
+	publicKeyLen:    32,
+	parsePublicKey:  ecdh.X25519().NewPublicKey,
+	encodePublicKey: (*ecdh.PublicKey).Bytes,
+	kdf:             ansiX963KDF,
+}
+
+// profileB is ECIES Profile B (C.3.4.2, TS 33.501) with secp256r1, where the
+// ephemeral public key is point-compressed.
+var profileB = eciesProfile{
+	curve:           ecdh.P256(),
+	publicKeyLen:    33,
+	parsePublicKey:  parseP256PublicKey,
+	encodePublicKey: compressP256PublicKey,
+	kdf:             ansiX963KDF,
+}
+
+// DeconcealProfileA decrypts the scheme output of ECIES Profile A (C.3.4.1, TS 33.501)
+// with the home network private key, and returns the plaintext (the BCD-encoded MSIN
+// for an IMSI-type SUPI).
+//
+// The scheme output is the ephemeral public key || ciphertext || MAC tag.
+func DeconcealProfileA(privateKey, schemeOutput []byte) ([]byte, error) {
+	return profileA.deconceal(privateKey, schemeOutput)
+}
+
+// ConcealProfileA encrypts the MSIN of SUPI with ECIES Profile A (C.3.4.1, TS 33.501) with
+// the home network public key and a newly generated ephemeral key pair, as the UE does, and
+// returns the scheme output, i.e. the ephemeral public key || ciphertext || MAC tag.
+//
+// SUPI should be an IMSI, either "imsi-<digits>" or the digits alone, which is split into
+// MCC, MNC and MSIN with aka.ParseIMSI. An error is returned for the other types of SUPI.
+// The result can be deconcealed with DeconcealProfileA.
+func ConcealProfileA(homeNetworkPublicKey []byte, supi string) ([]byte, error) {
+	return profileA.conceal(homeNetworkPublicKey, supi)
+}
+
+// DeconcealProfileB is DeconcealProfileA for ECIES Profile B (C.3.4.2, TS 33.501).
+// The ephemeral public key in the scheme output is compressed (33 bytes).
+func DeconcealProfileB(privateKey, schemeOutput []byte) ([]byte, error) {
+	return profileB.deconceal(privateKey, schemeOutput)
+}
+
+// ConcealProfileB is ConcealProfileA for ECIES Profile B (C.3.4.2, TS 33.501).
+// The home network public key may be either compressed or uncompressed.
+func ConcealProfileB(homeNetworkPublicKey []byte, supi string) ([]byte, error) {
+	return profileB.conceal(homeNetworkPublicKey, supi)
+}
+
+func (p eciesProfile) deconceal(privateKey, schemeOutput []byte) ([]byte, error) {
+	if len(schemeOutput) <= p.publicKeyLen+eciesMACLen {
+		return nil, fmt.Errorf("scheme output is too short: %d", len(schemeOutput))
+	}
+
+	priv, err := p.curve.NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid home network private key: %w", err)
+	}
+	ephPubBytes := schemeOutput[:p.publicKeyLen]
+	ephPub, err := p.parsePublicKey(ephPubBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral public key: %w", err)
+	}
+	shared, err := priv.ECDH(ephPub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	ciphertext := schemeOutput[p.publicKeyLen : len(schemeOutput)-eciesMACLen]
+	tag := schemeOutput[len(schemeOutput)-eciesMACLen:]
+
+	encKey, icb, macKey := p.deriveKeys(shared, ephPubBytes)
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil)[:eciesMACLen], tag) {
+		return nil, fmt.Errorf("MAC tag mismatch")
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, icb).XORKeyStream(plain, ciphertext)
+	return plain, nil
+}
+
+func (p eciesProfile) conceal(homeNetworkPublicKey []byte, supi string) ([]byte, error) {
+	digits, ok := strings.CutPrefix(supi, "imsi-")
+	if !ok && strings.Contains(supi, "-") {
+		return nil, fmt.Errorf("unsupported SUPI type: %s", supi)
+	}
+	imsi, err := aka.ParseIMSI(digits)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := encodeBCD(imsi.MSIN)
+	if err != nil {
+		return nil, err
+	}
+
+	eph, err := p.curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	return p.concealWithKey(homeNetworkPublicKey, eph, plain)
+}
+
+// concealWithKey encrypts plain with the ephemeral private key given, and returns
+// the scheme output.
+func (p eciesProfile) concealWithKey(homeNetworkPublicKey []byte, eph *ecdh.PrivateKey, plain []byte) ([]byte, error) {
+	hnPub, err := p.parsePublicKey(homeNetworkPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid home network public key: %w", err)
+	}
+	shared, err := eph.ECDH(hnPub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	ephPubBytes := p.encodePublicKey(eph.PublicKey())
+
+	encKey, icb, macKey := p.deriveKeys(shared, ephPubBytes)
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(plain))
+	cipher.NewCTR(block, icb).XORKeyStream(ciphertext, plain)
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ciphertext)
+
+	out := make([]byte, 0, len(ephPubBytes)+len(ciphertext)+eciesMACLen)
+	out = append(out, ephPubBytes...)
+	out = append(out, ciphertext...)
+	out = append(out, mac.Sum(nil)[:eciesMACLen]...)
+	return out, nil
+}
+
+// deriveKeys derives the encryption key, ICB and MAC key from the shared secret,
+// with the ephemeral public key as in the scheme output as the shared info.
+func (p eciesProfile) deriveKeys(shared, ephPubBytes []byte) (encKey, icb, macKey []byte) {
+	kd := p.kdf(shared, ephPubBytes, eciesEncKeyLen+eciesICBLen+eciesMACKeyLen)
+	return kd[:eciesEncKeyLen], kd[eciesEncKeyLen : eciesEncKeyLen+eciesICBLen], kd[eciesEncKeyLen+eciesICBLen:]
+}
+
+// parseP256PublicKey decodes a secp256r1 public key, either compressed or uncompressed.
+func parseP256PublicKey(b []byte) (*ecdh.PublicKey, error) {
+	if len(b) != 33 {
+		return ecdh.P256().NewPublicKey(b)
+	}
+
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), b)
+	if x == nil {
+		return nil, fmt.Errorf("invalid compressed point")
+	}
+	uncompressed := make([]byte, 65)
+	uncompressed[0] = 0x04
+	x.FillBytes(uncompressed[1:33])
+	y.FillBytes(uncompressed[33:])
+	return ecdh.P256().NewPublicKey(uncompressed)
+}
+
+// compressP256PublicKey encodes a secp256r1 public key in the compressed form.
+func compressP256PublicKey(pub *ecdh.PublicKey) []byte {
+	b := pub.Bytes() // 0x04 || X || Y
+	return append([]byte{0x02 | b[64]&1}, b[1:33]...)
+}
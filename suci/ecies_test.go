@@ -0,0 +1,175 @@
+package suci
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// mustHex decodes s in hex, failing the test if it's malformed.
+func mustHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		tb.Fatalf("hex.DecodeString(%q) failed: %v", s, err)
+	}
+	return b
+}
+
+// eciesTestVector is a test vector of an ECIES profile in C.4, TS 33.501,
+// where the plaintext is the BCD-encoded MSIN.
+type eciesTestVector struct {
+	profile                   eciesProfile
+	deconceal                 func(privateKey, schemeOutput []byte) ([]byte, error)
+	hnPrivateKey, hnPublicKey string
+	ephPrivateKey             string
+	plaintext, schemeOutput   string
+}
+
+var profileATestVector = eciesTestVector{
+	profile:       profileA,
+	deconceal:     DeconcealProfileA,
+	hnPrivateKey:  "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+	hnPublicKey:   "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+	ephPrivateKey: "c80949f13ebe61af4ebdbd293ea4f942696b9e815d7e8f0096bbf6ed7de62256",
+	plaintext:     "00012080f6",
+	schemeOutput:  "b2e92f836055a255837debf850b528997ce0201cb82adfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+}
+
+func testECIESVector(t *testing.T, v eciesTestVector) {
+	t.Helper()
+
+	plain, err := v.deconceal(mustHex(t, v.hnPrivateKey), mustHex(t, v.schemeOutput))
+	if err != nil {
+		t.Fatalf("deconceal failed: %v", err)
+	}
+	if want := mustHex(t, v.plaintext); !bytes.Equal(plain, want) {
+		t.Errorf("deconcealed plaintext = %x, want %x", plain, want)
+	}
+
+	eph, err := v.profile.curve.NewPrivateKey(mustHex(t, v.ephPrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := v.profile.concealWithKey(mustHex(t, v.hnPublicKey), eph, mustHex(t, v.plaintext))
+	if err != nil {
+		t.Fatalf("concealWithKey() failed: %v", err)
+	}
+	if want := mustHex(t, v.schemeOutput); !bytes.Equal(out, want) {
+		t.Errorf("scheme output = %x, want %x", out, want)
+	}
+}
+
+// testRoundTrip conceals SUPI with the public key of hnKey, and checks that ToSUPI
+// deconceals the SUCI built from the scheme output back into the same SUPI.
+func testRoundTrip(t *testing.T, scheme uint8, hnPublicKey []byte, hnKey *ecdh.PrivateKey,
+	conceal func(homeNetworkPublicKey []byte, supi string) ([]byte, error)) {
+	t.Helper()
+
+	for _, tt := range []struct {
+		supi, mcc, mnc string
+	}{
+		{"imsi-001010123456789", "001", "01"},
+		{"imsi-310260123456789", "310", "260"},
+	} {
+		out, err := conceal(hnPublicKey, tt.supi)
+		if err != nil {
+			t.Fatalf("conceal(%s) failed: %v", tt.supi, err)
+		}
+		s := fmt.Sprintf("suci-0-%s-%s-0000-%d-1-%x", tt.mcc, tt.mnc, scheme, out)
+		supi, err := ToSUPI(s, map[uint8][]byte{1: hnKey.Bytes()})
+		if err != nil {
+			t.Fatalf("ToSUPI(%s) failed: %v", s, err)
+		}
+		if supi != tt.supi {
+			t.Errorf("ToSUPI(conceal(%s)) = %s", tt.supi, supi)
+		}
+	}
+}
+
+func TestProfileATestVector(t *testing.T) {
+	testECIESVector(t, profileATestVector)
+}
+
+func TestConcealProfileARoundTrip(t *testing.T) {
+	hnKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRoundTrip(t, ProfileA, hnKey.PublicKey().Bytes(), hnKey, ConcealProfileA)
+}
+
+func TestConcealProfileANotIMSI(t *testing.T) {
+	hnPub := mustHex(t, profileATestVector.hnPublicKey)
+	for _, supi := range []string{"nai-user@example.com", "0123456789", "imsi-00101012345678x"} {
+		if out, err := ConcealProfileA(hnPub, supi); err == nil {
+			t.Errorf("ConcealProfileA(%s) = %x, want an error", supi, out)
+		}
+	}
+}
+
+var profileBTestVector = eciesTestVector{
+	profile:       profileB,
+	deconceal:     DeconcealProfileB,
+	hnPrivateKey:  "f1ab1074477ebcc7f554ea1c5fc368b1616730155e0041ac447d6301975fecda",
+	hnPublicKey:   "0272da71976234ce833a6907425867b82e074d44ef907dfb4b3e21c1c2256ebcd1",
+	ephPrivateKey: "99798858a1dc6a2c68637149a4b1dbfd1fdff5addd62a2142f06699ed7602529",
+	plaintext:     "00012080f6",
+	schemeOutput:  "039aab8376597021e855679a9778ea0b67396e68c66df32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d",
+}
+
+func TestProfileBTestVector(t *testing.T) {
+	testECIESVector(t, profileBTestVector)
+}
+
+func TestConcealProfileBRoundTrip(t *testing.T) {
+	hnKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("uncompressed", func(t *testing.T) {
+		testRoundTrip(t, ProfileB, hnKey.PublicKey().Bytes(), hnKey, ConcealProfileB)
+	})
+	t.Run("compressed", func(t *testing.T) {
+		testRoundTrip(t, ProfileB, compressP256PublicKey(hnKey.PublicKey()), hnKey, ConcealProfileB)
+	})
+}
+
+func TestConcealProfileBCompressedEphemeralKey(t *testing.T) {
+	hnKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// enough runs for both parities of Y to come up
+	for i := 0; i < 16; i++ {
+		out, err := ConcealProfileB(hnKey.PublicKey().Bytes(), "imsi-001010123456789")
+		if err != nil {
+			t.Fatalf("ConcealProfileB() failed: %v", err)
+		}
+		if len(out) != 33+5+eciesMACLen {
+			t.Fatalf("length of scheme output = %d, want %d", len(out), 33+5+eciesMACLen)
+		}
+		if out[0] != 0x02 && out[0] != 0x03 {
+			t.Fatalf("ephemeral public key = %x, want a compressed point", out[:33])
+		}
+		if _, err := parseP256PublicKey(out[:33]); err != nil {
+			t.Fatalf("parseP256PublicKey(%x) failed: %v", out[:33], err)
+		}
+	}
+}
+
+func TestDeconcealTamperedMAC(t *testing.T) {
+	for _, v := range []eciesTestVector{profileATestVector, profileBTestVector} {
+		out := mustHex(t, v.schemeOutput)
+		for _, i := range []int{0, v.profile.publicKeyLen, len(out) - 1} {
+			tampered := bytes.Clone(out)
+			tampered[i] ^= 0x01
+			if plain, err := v.deconceal(mustHex(t, v.hnPrivateKey), tampered); err == nil {
+				t.Errorf("deconceal with byte %d of %x modified = %x, want an error", i, out, plain)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Package suci provides the deconcealment of SUCI (Subscription Concealed Identifier)
+into SUPI as described in TS 33.501 Annex C, to be used by the UDM (SIDF),
+and the concealment on the UE side for testing.
+*/
+package suci
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Protection scheme identifiers (Annex C.1, TS 33.501).
+const (
+	NullScheme uint8 = 0
+	ProfileA   uint8 = 1
+	ProfileB   uint8 = 2
+)
+
+// SUCI is a SUCI in the string form used in the SBI (e.g. TS 29.503), i.e.
+// suci-<SUPI type>-<MCC>-<MNC>-<routing indicator>-<protection scheme>-<public key ID>-<scheme output>.
+type SUCI struct {
+	SUPIType         string
+	MCC              string
+	MNC              string
+	RoutingIndicator string
+	ProtectionScheme uint8
+	PublicKeyID      uint8
+	SchemeOutput     string
+}
+
+// Parse parses SUCI in the string form.
+func Parse(s string) (*SUCI, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 8 || parts[0] != "suci" {
+		return nil, fmt.Errorf("invalid SUCI: %s", s)
+	}
+
+	scheme, err := strconv.ParseUint(parts[5], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protection scheme in SUCI %s: %w", s, err)
+	}
+	keyID, err := strconv.ParseUint(parts[6], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key ID in SUCI %s: %w", s, err)
+	}
+
+	return &SUCI{
+		SUPIType:         parts[1],
+		MCC:              parts[2],
+		MNC:              parts[3],
+		RoutingIndicator: parts[4],
+		ProtectionScheme: uint8(scheme),
+		PublicKeyID:      uint8(keyID),
+		SchemeOutput:     parts[7],
+	}, nil
+}
+
+// ToSUPI deconceals SUCI into SUPI in the form of "imsi-<MCC><MNC><MSIN>".
+//
+// privateKeys is the set of the home network private keys indexed by the public key ID.
+// It's not used for the null scheme.
+func ToSUPI(s string, privateKeys map[uint8][]byte) (string, error) {
+	suci, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if suci.SUPIType != "0" {
+		return "", fmt.Errorf("unsupported SUPI type: %s", suci.SUPIType)
+	}
+
+	var msin string
+	switch suci.ProtectionScheme {
+	case NullScheme:
+		msin = suci.SchemeOutput
+	case ProfileA, ProfileB:
+		key, ok := privateKeys[suci.PublicKeyID]
+		if !ok {
+			return "", fmt.Errorf("no private key found for public key ID: %d", suci.PublicKeyID)
+		}
+		out, err := hex.DecodeString(suci.SchemeOutput)
+		if err != nil {
+			return "", fmt.Errorf("invalid scheme output: %w", err)
+		}
+		deconceal := DeconcealProfileA
+		if suci.ProtectionScheme == ProfileB {
+			deconceal = DeconcealProfileB
+		}
+		plain, err := deconceal(key, out)
+		if err != nil {
+			return "", err
+		}
+		msin = decodeBCD(plain)
+	default:
+		return "", fmt.Errorf("unsupported protection scheme: %d", suci.ProtectionScheme)
+	}
+
+	return "imsi-" + suci.MCC + suci.MNC + msin, nil
+}
+
+// ansiX963KDF is the key derivation function in ANSI X9.63 (SEC 1, 3.6.1) with SHA-256.
+func ansiX963KDF(z, sharedInfo []byte, length int) []byte {
+	var (
+		out     []byte
+		counter = make([]byte, 4)
+	)
+	for i := uint32(1); len(out) < length; i++ {
+		binary.BigEndian.PutUint32(counter, i)
+		h := sha256.New()
+		h.Write(z)
+		h.Write(counter)
+		h.Write(sharedInfo)
+		out = h.Sum(out)
+	}
+	return out[:length]
+}
+
+// decodeBCD decodes the digits in BCD with swapped nibbles, dropping the filler (0xf).
+func decodeBCD(b []byte) string {
+	var sb strings.Builder
+	for _, v := range b {
+		for _, d := range []byte{v & 0x0f, v >> 4} {
+			if d == 0x0f {
+				continue
+			}
+			sb.WriteByte('0' + d)
+		}
+	}
+	return sb.String()
+}
+
+// encodeBCD encodes the digits in BCD with swapped nibbles, padding with the filler (0xf)
+// if the number of digits is odd.
+func encodeBCD(digits string) ([]byte, error) {
+	if digits == "" {
+		return nil, fmt.Errorf("no digits to encode")
+	}
+
+	b := make([]byte, (len(digits)+1)/2)
+	for i, c := range digits {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid digit in %s: %c", digits, c)
+		}
+		if i%2 == 0 {
+			b[i/2] = 0xf0 | byte(c-'0')
+		} else {
+			b[i/2] = b[i/2]&0x0f | byte(c-'0')<<4
+		}
+	}
+	return b, nil
+}
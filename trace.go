@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// traceLine is an intermediate value traced during aka.RunFlow.
+type traceLine struct {
+	name  string
+	value []byte
+}
+
+// traceBuffer holds the intermediate values traced during aka.RunFlow, so that they
+// can be printed under the section of the network function that computes them
+// instead of all before the first section.
+type traceBuffer struct {
+	lines []traceLine
+}
+
+// add is an aka.FlowParams.Trace that appends the value to b.
+func (b *traceBuffer) add(name string, value []byte) {
+	b.lines = append(b.lines, traceLine{name: name, value: append([]byte(nil), value...)})
+}
+
+// printUntil prints the buffered values up to and including the first one named name
+// to w, and drops them from b. All of them are printed if none is named name.
+func (b *traceBuffer) printUntil(w io.Writer, name string) {
+	n := len(b.lines)
+	for i, l := range b.lines {
+		if l.name == name {
+			n = i + 1
+			break
+		}
+	}
+
+	for _, l := range b.lines[:n] {
+		fmt.Fprintf(w, "  %-10s= %x\n", l.name, l.value)
+	}
+	b.lines = b.lines[n:]
+}
@@ -0,0 +1,99 @@
+package udm
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LoadCSV reads the credentials of subscribers from r and adds them to s.
+//
+// Each row is either "imsi,ki,value,indicator" or "imsi,ki,prefix:value", in the way
+// the osmo-hlr style provisioning files tell whether the third column is OP or OPc:
+//
+//   - indicator is "opc", "1" or "true" for OPc and "op", "0" or "false" for OP
+//   - prefix is "opc" or "op"
+//
+// A header row starting with "imsi" is skipped. SQN starts from 0 and AMF is 0x8000.
+func (s *MemoryCredentialStore) LoadCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if line == 1 && strings.EqualFold(row[0], "imsi") {
+			continue
+		}
+
+		c, err := parseCSVRow(row)
+		if err != nil {
+			return fmt.Errorf("invalid row at line %d: %w", line, err)
+		}
+		s.Add("imsi-"+row[0], c)
+	}
+}
+
+func parseCSVRow(row []string) (*Credentials, error) {
+	if len(row) != 3 && len(row) != 4 {
+		return nil, fmt.Errorf("number of columns should be 3 or 4, got: %d", len(row))
+	}
+
+	k, err := hex.DecodeString(row[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ki: %w", err)
+	}
+
+	value := row[2]
+	var isOPc bool
+	if len(row) == 4 {
+		switch strings.ToLower(row[3]) {
+		case "opc":
+			isOPc = true
+		case "op":
+			isOPc = false
+		default:
+			isOPc, err = strconv.ParseBool(row[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid OP/OPc indicator: %s", row[3])
+			}
+		}
+	} else {
+		prefix, v, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("OP/OPc indicator is missing: %s", value)
+		}
+		switch strings.ToLower(prefix) {
+		case "opc":
+			isOPc = true
+		case "op":
+			isOPc = false
+		default:
+			return nil, fmt.Errorf("invalid OP/OPc prefix: %s", prefix)
+		}
+		value = v
+	}
+
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OP/OPc: %w", err)
+	}
+
+	c := &Credentials{K: k, AMF: 0x8000}
+	if isOPc {
+		c.OPc = b
+	} else {
+		c.OP = b
+	}
+	return c, nil
+}
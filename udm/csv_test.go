@@ -0,0 +1,67 @@
+package udm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadCSV(t *testing.T) {
+	const in = `imsi,ki,opc,indicator
+001010000000001,00112233445566778899aabbccddeeff,62e75b8d6fa5bf46ec87a9276f9df54d,opc
+001010000000002,00112233445566778899aabbccddeeff,cdc202d5123e20f62b6d676ac72cb318,0
+001010000000003, 00112233445566778899aabbccddeeff, op:cdc202d5123e20f62b6d676ac72cb318
+001010000000004,00112233445566778899aabbccddeeff,OPC:62e75b8d6fa5bf46ec87a9276f9df54d
+`
+	s := NewMemoryCredentialStore()
+	if err := s.LoadCSV(strings.NewReader(in)); err != nil {
+		t.Fatalf("LoadCSV() failed: %v", err)
+	}
+
+	k := mustHex(t, "00112233445566778899aabbccddeeff")
+	op := mustHex(t, "cdc202d5123e20f62b6d676ac72cb318")
+	opc := mustHex(t, "62e75b8d6fa5bf46ec87a9276f9df54d")
+	tests := []struct {
+		supi    string
+		op, opc []byte
+	}{
+		{"imsi-001010000000001", nil, opc},
+		{"imsi-001010000000002", op, nil},
+		{"imsi-001010000000003", op, nil},
+		{"imsi-001010000000004", nil, opc},
+	}
+	for _, tt := range tests {
+		c, err := s.Get(tt.supi)
+		if err != nil {
+			t.Errorf("Get(%s) failed: %v", tt.supi, err)
+			continue
+		}
+		if !bytes.Equal(c.K, k) || !bytes.Equal(c.OP, tt.op) || !bytes.Equal(c.OPc, tt.opc) {
+			t.Errorf("Get(%s) = K %x, OP %x, OPc %x, want K %x, OP %x, OPc %x",
+				tt.supi, c.K, c.OP, c.OPc, k, tt.op, tt.opc)
+		}
+		if c.SQN != 0 || c.AMF != 0x8000 {
+			t.Errorf("Get(%s) = SQN %d, AMF %#04x, want SQN 0, AMF 0x8000", tt.supi, c.SQN, c.AMF)
+		}
+	}
+}
+
+func TestLoadCSVInvalid(t *testing.T) {
+	tests := []struct {
+		name, in string
+	}{
+		{"too few columns", "001010000000001,00112233445566778899aabbccddeeff\n"},
+		{"no indicator", "001010000000001,00112233445566778899aabbccddeeff,62e75b8d6fa5bf46ec87a9276f9df54d\n"},
+		{"invalid indicator", "001010000000001,00112233445566778899aabbccddeeff,62e75b8d6fa5bf46ec87a9276f9df54d,x\n"},
+		{"invalid prefix", "001010000000001,00112233445566778899aabbccddeeff,key:62e75b8d6fa5bf46ec87a9276f9df54d\n"},
+		{"invalid Ki", "001010000000001,zz,op:62e75b8d6fa5bf46ec87a9276f9df54d\n"},
+		{"invalid OP", "001010000000001,00112233445566778899aabbccddeeff,op:zz\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewMemoryCredentialStore().LoadCSV(strings.NewReader(tt.in)); err == nil {
+				t.Error("LoadCSV() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Package udm provides a stub of the UDM that serves Nudm_UEAuthentication_Get,
+i.e. it deconceals SUCI into SUPI, looks up the credentials of the subscriber and
+generates a 5G HE AV with milenage and aka.
+*/
+package udm
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+
+	"5G_AKA/aka"
+	"5G_AKA/milenage"
+	"5G_AKA/suci"
+)
+
+// Credentials is the authentication subscription data of a subscriber.
+//
+// Either OP or OPc should be given. If both are given, OPc is used.
+type Credentials struct {
+	K   []byte
+	OP  []byte
+	OPc []byte
+	SQN uint64
+	AMF uint16
+}
+
+// Milenage creates a Milenage with the credentials and the RAND and SQN given,
+// using NewWithOPc if OPc is set or New otherwise.
+func (c *Credentials) Milenage(rand []byte, sqn uint64) *milenage.Milenage {
+	if c.OPc != nil {
+		return milenage.NewWithOPc(c.K, c.OPc, rand, sqn, c.AMF)
+	}
+	return milenage.New(c.K, c.OP, rand, sqn, c.AMF)
+}
+
+// CredentialStore is a store of Credentials indexed by SUPI ("imsi-<digits>").
+type CredentialStore interface {
+	Get(supi string) (*Credentials, error)
+	SetSQN(supi string, sqn uint64) error
+}
+
+// MemoryCredentialStore is a CredentialStore that keeps Credentials in memory.
+// It's safe for concurrent use.
+type MemoryCredentialStore struct {
+	mu sync.RWMutex
+	m  map[string]*Credentials
+}
+
+// NewMemoryCredentialStore creates an empty MemoryCredentialStore.
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{m: make(map[string]*Credentials)}
+}
+
+// Add adds or replaces Credentials of the SUPI.
+func (s *MemoryCredentialStore) Add(supi string, c *Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cc := *c
+	s.m[supi] = &cc
+}
+
+// Get returns a copy of Credentials of the SUPI.
+func (s *MemoryCredentialStore) Get(supi string) (*Credentials, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.m[supi]
+	if !ok {
+		return nil, fmt.Errorf("no credentials found for SUPI: %s", supi)
+	}
+	cc := *c
+	return &cc, nil
+}
+
+// SetSQN updates SQN of the SUPI.
+func (s *MemoryCredentialStore) SetSQN(supi string, sqn uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.m[supi]
+	if !ok {
+		return fmt.Errorf("no credentials found for SUPI: %s", supi)
+	}
+	c.SQN = sqn
+	return nil
+}
+
+// UDM is a stub of the UDM.
+type UDM struct {
+	// Store is where the credentials are looked up.
+	Store CredentialStore
+
+	// PrivateKeys is the set of the home network private keys indexed by
+	// the public key ID, used to deconceal SUCI.
+	PrivateKeys map[uint8][]byte
+
+	// MCC and MNC of the serving network. If empty, the home network
+	// in SUCI is used.
+	MCC string
+	MNC string
+
+	// mu serializes the SQN advancement.
+	mu sync.Mutex
+}
+
+// GetAuthVector deconceals SUCI into SUPI, advances SQN of the subscriber
+// and returns a 5G HE AV generated with a random RAND, as the UDM does
+// on Nudm_UEAuthentication_Get (6.1.3.2, TS 33.501).
+func (u *UDM) GetAuthVector(s string) (*aka.HEAuthVector, string, error) {
+	parsed, err := suci.Parse(s)
+	if err != nil {
+		return nil, "", err
+	}
+	supi, err := suci.ToSUPI(s, u.PrivateKeys)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to deconceal SUCI: %w", err)
+	}
+
+	mcc, mnc := u.MCC, u.MNC
+	if mcc == "" {
+		mcc, mnc = parsed.MCC, parsed.MNC
+	}
+	snn, err := milenage.ServingNetworkName(mcc, mnc)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sqn, c, err := u.advanceSQN(supi)
+	if err != nil {
+		return nil, "", err
+	}
+
+	r := make([]byte, 16)
+	if _, err := rand.Read(r); err != nil {
+		return nil, "", fmt.Errorf("failed to generate RAND: %w", err)
+	}
+
+	m := c.Milenage(r, sqn)
+	if _, err := m.F1(); err != nil {
+		return nil, "", fmt.Errorf("F1() failed: %w", err)
+	}
+	if _, _, _, _, err := m.F2345(); err != nil {
+		return nil, "", fmt.Errorf("F2345() failed: %w", err)
+	}
+	m.RESStar, err = m.ComputeRESStarSNN(snn)
+	if err != nil {
+		return nil, "", fmt.Errorf("ComputeRESStar() failed: %w", err)
+	}
+	autn, err := m.GenerateAUTN()
+	if err != nil {
+		return nil, "", fmt.Errorf("GenerateAUTN() failed: %w", err)
+	}
+	kausf, err := aka.New(m, snn, strings.TrimPrefix(supi, "imsi-")).ComputeKAUSF()
+	if err != nil {
+		return nil, "", fmt.Errorf("ComputeKAUSF() failed: %w", err)
+	}
+
+	return &aka.HEAuthVector{
+		RAND:     r,
+		AUTN:     autn,
+		XRESStar: m.RESStar,
+		KAUSF:    kausf,
+	}, supi, nil
+}
+
+// indBits is the number of bits of IND in SQN, as recommended in TS 33.102 Annex C.3.2.
+const indBits = 5
+
+// advanceSQN advances SQN of the subscriber to the next one with milenage.SQNScheme,
+// i.e. SEQ incremented by 1 and IND moved to the next value, and returns the new value
+// with the credentials.
+func (u *UDM) advanceSQN(supi string) (uint64, *Credentials, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	c, err := u.Store.Get(supi)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sqn := milenage.NewSQNScheme(c.SQN, indBits, 0).Next()
+	if err := u.Store.SetSQN(supi, sqn); err != nil {
+		return 0, nil, fmt.Errorf("failed to update SQN: %w", err)
+	}
+	return sqn, c, nil
+}
@@ -0,0 +1,106 @@
+package udm
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"5G_AKA/aka"
+	"5G_AKA/suci"
+)
+
+// mustHex decodes s in hex, failing the test if it's malformed.
+func mustHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		tb.Fatalf("hex.DecodeString(%q) failed: %v", s, err)
+	}
+	return b
+}
+
+func TestGetAuthVector(t *testing.T) {
+	const supi = "imsi-001010123456789"
+	c := &Credentials{
+		K:   mustHex(t, "00112233445566778899aabbccddeeff"),
+		OPc: mustHex(t, "62e75b8d6fa5bf46ec87a9276f9df54d"),
+		SQN: 1,
+		AMF: 0x8000,
+	}
+	store := NewMemoryCredentialStore()
+	store.Add(supi, c)
+
+	hnKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &UDM{
+		Store:       store,
+		PrivateKeys: map[uint8][]byte{1: hnKey.Bytes()},
+	}
+
+	lastSQN := c.SQN
+	for i := 0; i < 2; i++ {
+		out, err := suci.ConcealProfileA(hnKey.PublicKey().Bytes(), supi)
+		if err != nil {
+			t.Fatalf("ConcealProfileA() failed: %v", err)
+		}
+		s := fmt.Sprintf("suci-0-001-01-0000-1-1-%x", out)
+
+		av, gotSUPI, err := u.GetAuthVector(s)
+		if err != nil {
+			t.Fatalf("GetAuthVector() failed: %v", err)
+		}
+		if gotSUPI != supi {
+			t.Errorf("GetAuthVector() SUPI = %s, want %s", gotSUPI, supi)
+		}
+
+		stored, err := store.Get(supi)
+		if err != nil {
+			t.Fatalf("Get() failed: %v", err)
+		}
+		if stored.SQN <= lastSQN {
+			t.Errorf("SQN after GetAuthVector() #%d = %#x, want greater than %#x", i+1, stored.SQN, lastSQN)
+		}
+		lastSQN = stored.SQN
+
+		r, err := aka.RunFlow(aka.FlowParams{
+			IMSI: "001010123456789",
+			MCC:  "001",
+			MNC:  "01",
+			K:    c.K,
+			OPc:  c.OPc,
+			SQN:  stored.SQN,
+			AMF:  c.AMF,
+			RAND: av.RAND,
+		})
+		if err != nil {
+			t.Fatalf("RunFlow() failed: %v", err)
+		}
+		for _, v := range []struct {
+			name      string
+			got, want []byte
+		}{
+			{"AUTN", av.AUTN, r.AUTN},
+			{"XRES*", av.XRESStar, r.XRESStar},
+			{"KAUSF", av.KAUSF, r.KAUSF},
+		} {
+			if !bytes.Equal(v.got, v.want) {
+				t.Errorf("%s = %x, want %x", v.name, v.got, v.want)
+			}
+		}
+	}
+}
+
+func TestGetAuthVectorUnknownKeyID(t *testing.T) {
+	u := &UDM{
+		Store:       NewMemoryCredentialStore(),
+		PrivateKeys: map[uint8][]byte{},
+	}
+	if _, _, err := u.GetAuthVector("suci-0-001-01-0000-1-1-00"); err == nil {
+		t.Error("GetAuthVector() with an unknown public key ID succeeded, want an error")
+	}
+}